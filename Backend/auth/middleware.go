@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/ansh0014/KolamApp/model"
+	"github.com/ansh0014/KolamApp/repository"
+)
+
+type ctxKey struct{}
+
+// ErrorWriter writes the error responses of Middleware and Required, so a
+// server can use its own error format. A nil ErrorWriter writes plain text.
+type ErrorWriter func(w http.ResponseWriter, status int, message string)
+
+func (write ErrorWriter) error(w http.ResponseWriter, status int, message string) {
+	if write == nil {
+		http.Error(w, message, status)
+		return
+	}
+	write(w, status, message)
+}
+
+func (write ErrorWriter) unauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="kolam"`)
+	write.error(w, http.StatusUnauthorized, msg)
+}
+
+// WithUser returns a copy of ctx carrying u.
+func WithUser(ctx context.Context, u *model.User) context.Context {
+	return context.WithValue(ctx, ctxKey{}, u)
+}
+
+// UserFrom returns the authenticated user, or nil for anonymous requests.
+func UserFrom(ctx context.Context) *model.User {
+	u, _ := ctx.Value(ctxKey{}).(*model.User)
+	return u
+}
+
+// Middleware resolves an "Authorization: Bearer <access token>" header to a
+// user and attaches it to the request context. Requests without the header
+// pass through anonymously; a bad or expired token is rejected with 401.
+// Errors are written with write.
+func Middleware(iss *Issuer, users repository.UserRepository, write ErrorWriter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := r.Header.Get("Authorization")
+			if h == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			token, ok := strings.CutPrefix(h, "Bearer ")
+			if !ok {
+				write.unauthorized(w, "authorization header must be a bearer token")
+				return
+			}
+			claims, err := iss.Verify(strings.TrimSpace(token), Access)
+			if errors.Is(err, ErrExpiredToken) {
+				write.unauthorized(w, "access token expired")
+				return
+			}
+			if err != nil {
+				write.unauthorized(w, "invalid access token")
+				return
+			}
+			u, err := users.Get(r.Context(), claims.Subject)
+			if errors.Is(err, repository.ErrNotFound) || errors.Is(err, repository.ErrInvalidID) {
+				write.unauthorized(w, "unknown user")
+				return
+			}
+			if err != nil {
+				log.Printf("auth: load user %s: %v", claims.Subject, err)
+				write.error(w, http.StatusInternalServerError, "failed to load user")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), u)))
+		})
+	}
+}
+
+// Required returns a wrapper that rejects anonymous requests with 401,
+// written with write.
+func Required(write ErrorWriter) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if UserFrom(r.Context()) == nil {
+				write.unauthorized(w, "authentication required")
+				return
+			}
+			next(w, r)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength is the shortest password accepted at signup.
+const MinPasswordLength = 8
+
+// ErrWeakPassword is returned by HashPassword for passwords that are too short or too long.
+var ErrWeakPassword = errors.New("password must be 8 to 72 bytes")
+
+// HashPassword returns the bcrypt hash of password.
+func HashPassword(password string) (string, error) {
+	// bcrypt ignores everything past 72 bytes, so reject rather than truncate.
+	if len(password) < MinPasswordLength || len(password) > 72 {
+		return "", ErrWeakPassword
+	}
+	h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(h), nil
+}
+
+// CheckPassword reports whether password matches the bcrypt hash.
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
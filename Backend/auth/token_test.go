@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	i := &Issuer{Secret: []byte("secret"), AccessTTL: time.Hour, RefreshTTL: time.Hour}
+	access, _, err := i.Issue("u1", Access)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refresh, _, err := i.Issue("u1", Refresh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _, err := (&Issuer{Secret: i.Secret, AccessTTL: -time.Minute}).Issue("u1", Access)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := (&Issuer{Secret: []byte("other secret"), AccessTTL: time.Hour}).Issue("u1", Access)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(access, ".")
+
+	c, err := i.Verify(access, Access)
+	if err != nil || c.Subject != "u1" || c.Type != Access {
+		t.Fatalf("Verify(access) = %+v, %v", c, err)
+	}
+	tests := []struct {
+		name, token, typ string
+		want             error
+	}{
+		{"wrong signature", other, Access, ErrInvalidToken},
+		{"tampered payload", parts[0] + "." + strings.Split(refresh, ".")[1] + "." + parts[2], Refresh, ErrInvalidToken},
+		{"missing signature", parts[0] + "." + parts[1], Access, ErrInvalidToken},
+		{"expired", expired, Access, ErrExpiredToken},
+		{"refresh token used as access", refresh, Access, ErrInvalidToken},
+		{"access token used as refresh", access, Refresh, ErrInvalidToken},
+		{"empty", "", Access, ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		if _, err := i.Verify(tt.token, tt.typ); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Package derivative makes the smaller renditions of stored images that the
+// gallery and AR views load instead of the full-resolution original.
+package derivative
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"path"
+	"strings"
+
+	"github.com/ansh0014/KolamApp/model"
+	"github.com/ansh0014/KolamApp/storage"
+)
+
+// Size names. Full is the original image, which has no derivative.
+const (
+	Thumb  = "thumb"
+	Medium = "medium"
+	Full   = "full"
+)
+
+// Size is one derivative: the image scaled to fit within MaxSide pixels a
+// side, encoded as JPEG at Quality when it is opaque and as PNG otherwise.
+type Size struct {
+	Name    string
+	MaxSide int
+	Quality int
+}
+
+// Sizes are made for every stored image, smallest first.
+var Sizes = []Size{
+	{Name: Thumb, MaxSide: 320, Quality: 80},
+	{Name: Medium, MaxSide: 1280, Quality: 85},
+}
+
+// Valid reports whether size names a derivative or the original.
+func Valid(size string) bool {
+	if size == Full {
+		return true
+	}
+	for _, s := range Sizes {
+		if s.Name == size {
+			return true
+		}
+	}
+	return false
+}
+
+// keyFor names the size derivative of key: "kolam_1.png" becomes "kolam_1_thumb.jpg".
+func keyFor(key, size, ext string) string {
+	return strings.TrimSuffix(key, path.Ext(key)) + "_" + size + ext
+}
+
+// Make decodes the image stored under key, whose bytes are data, and stores
+// each derivative smaller than it next to it. Images already within a size
+// get no derivative for it; the original serves in its place.
+func Make(ctx context.Context, store storage.BlobStore, key string, data []byte) ([]model.Derivative, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	orientation := 1
+	if format == "jpeg" {
+		orientation = jpegOrientation(data)
+	}
+	return makeFrom(ctx, store, key, img, orientation)
+}
+
+// FromImage is Make for an image already in memory, such as a kolam just
+// rendered, which saves decoding the stored copy again.
+func FromImage(ctx context.Context, store storage.BlobStore, key string, img image.Image) ([]model.Derivative, error) {
+	return makeFrom(ctx, store, key, img, 1)
+}
+
+func makeFrom(ctx context.Context, store storage.BlobStore, key string, img image.Image, orientation int) ([]model.Derivative, error) {
+	sw, sh := img.Bounds().Dx(), img.Bounds().Dy()
+
+	out := []model.Derivative{}
+	for _, s := range Sizes {
+		w, h := fit(sw, sh, s.MaxSide)
+		if w == sw && h == sh {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return out, err
+		}
+		scaled := orient(resize(img, w, h), orientation)
+
+		var buf bytes.Buffer
+		var err error
+		ext, contentType := ".png", "image/png"
+		if scaled.Opaque() {
+			ext, contentType = ".jpg", "image/jpeg"
+			err = jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: s.Quality})
+		} else {
+			err = png.Encode(&buf, scaled)
+		}
+		if err != nil {
+			return out, fmt.Errorf("encode %s: %w", s.Name, err)
+		}
+		dkey := keyFor(key, s.Name, ext)
+		size := int64(buf.Len())
+		obj, err := store.Put(ctx, dkey, &buf, contentType)
+		if err != nil {
+			return out, fmt.Errorf("store %s: %w", s.Name, err)
+		}
+		out = append(out, model.Derivative{
+			Size:        s.Name,
+			Filename:    dkey,
+			URL:         obj.URL,
+			Width:       scaled.Rect.Dx(),
+			Height:      scaled.Rect.Dy(),
+			ContentType: contentType,
+			Bytes:       size,
+			SHA256:      obj.SHA256,
+		})
+	}
+	return out, nil
+}
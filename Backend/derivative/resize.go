@@ -0,0 +1,124 @@
+package derivative
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// fit returns the size of a w × h image scaled down to fit within
+// maxSide × maxSide, keeping its aspect ratio.
+func fit(w, h, maxSide int) (int, int) {
+	if w <= maxSide && h <= maxSide {
+		return w, h
+	}
+	if w >= h {
+		return maxSide, max(1, int(math.Round(float64(h)*float64(maxSide)/float64(w))))
+	}
+	return max(1, int(math.Round(float64(w)*float64(maxSide)/float64(h)))), maxSide
+}
+
+// tap is one source pixel's share of a destination pixel.
+type tap struct {
+	i int
+	w float32
+}
+
+// taps returns, for each of dn destination pixels, the source pixels among
+// sn that it covers and how much of each.
+func taps(sn, dn int) [][]tap {
+	scale := float64(sn) / float64(dn)
+	out := make([][]tap, dn)
+	for d := range out {
+		a, b := float64(d)*scale, float64(d+1)*scale
+		for i := int(a); i < sn && float64(i) < b; i++ {
+			cover := math.Min(b, float64(i+1)) - math.Max(a, float64(i))
+			if cover > 0 {
+				out[d] = append(out[d], tap{i, float32(cover / scale)})
+			}
+		}
+	}
+	return out
+}
+
+// resize scales src down to dw × dh by area averaging: every destination
+// pixel is the mean of the source pixels under it, which keeps thin kolam
+// lines visible where point sampling would drop them. Source rows are
+// converted one at a time, so memory stays proportional to the output.
+func resize(src image.Image, dw, dh int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	xt, yt := taps(sw, dw), taps(sh, dh)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	row := image.NewRGBA(image.Rect(0, 0, sw, 1))
+	converted := -1
+	acc := make([]float32, dw*4)
+	for y := 0; y < dh; y++ {
+		clear(acc)
+		for _, ty := range yt[y] {
+			if ty.i != converted {
+				// premultiplied RGBA, so transparent pixels do not bleed colour
+				draw.Draw(row, row.Bounds(), src, image.Pt(b.Min.X, b.Min.Y+ty.i), draw.Src)
+				converted = ty.i
+			}
+			for x, tx := range xt {
+				var r, g, bl, a float32
+				for _, t := range tx {
+					p := row.Pix[t.i*4 : t.i*4+4 : t.i*4+4]
+					r += float32(p[0]) * t.w
+					g += float32(p[1]) * t.w
+					bl += float32(p[2]) * t.w
+					a += float32(p[3]) * t.w
+				}
+				o := x * 4
+				acc[o] += r * ty.w
+				acc[o+1] += g * ty.w
+				acc[o+2] += bl * ty.w
+				acc[o+3] += a * ty.w
+			}
+		}
+		out := dst.Pix[y*dst.Stride : y*dst.Stride+dw*4]
+		for i, v := range acc {
+			out[i] = uint8(min(255, v+0.5))
+		}
+	}
+	return dst
+}
+
+// orient applies an EXIF orientation (1 to 8) to img, so the derivative is
+// upright without needing the tag.
+func orient(img *image.RGBA, o int) *image.RGBA {
+	if o < 2 || o > 8 {
+		return img
+	}
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var nx, ny int
+			switch o {
+			case 2: // mirrored
+				nx, ny = w-1-x, y
+			case 3: // upside down
+				nx, ny = w-1-x, h-1-y
+			case 4: // upside down and mirrored
+				nx, ny = x, h-1-y
+			case 5: // transposed
+				nx, ny = y, x
+			case 6: // needs a quarter turn clockwise
+				nx, ny = h-1-y, x
+			case 7: // transverse
+				nx, ny = h-1-y, w-1-x
+			case 8: // needs a quarter turn anticlockwise
+				nx, ny = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(nx, ny):dst.PixOffset(nx, ny)+4], img.Pix[img.PixOffset(x, y):img.PixOffset(x, y)+4])
+		}
+	}
+	return dst
+}
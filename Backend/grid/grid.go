@@ -0,0 +1,303 @@
+// Package grid parses the traditional kolam dot-grid notations into a DotGrid.
+//
+// Supported specs:
+//
+//	"N", "NxN"      square grid, N rows of N dots
+//	"RxC"           rectangular grid, R rows of C dots
+//	"1-N-1"         diamond: rows of 1, 2, ..., N, ..., 2, 1 dots
+//	"a-b-a"         any three-part spec with a < b expands the same way (3-5-3 = 3,4,5,4,3)
+//	"5-4-5-4-5"     explicit row counts; rows are centred on each other
+//	"RxCi"          interleaved: R rows alternating C and C-1 dots
+//
+// Rows whose dot counts differ by one are offset by half a spacing, which places
+// the dots on a square lattice rotated by 45°. Rows whose counts differ by an even
+// number stay on an axis-aligned lattice.
+package grid
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Limits keep a single grid small enough to generate and render in one request.
+const (
+	MaxSide = 128
+	MaxDots = 16384
+)
+
+// Kind is the family of grid notation a spec was written in.
+type Kind string
+
+const (
+	Square      Kind = "square"
+	Rect        Kind = "rect"
+	Diamond     Kind = "diamond"
+	Interleaved Kind = "interleaved"
+)
+
+// ErrInvalidSpec is wrapped by every parse error.
+var ErrInvalidSpec = errors.New("invalid grid spec")
+
+// Error describes why a spec was rejected.
+type Error struct {
+	Spec   string `json:"spec"`
+	Reason string `json:"reason"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid grid spec %q: %s", e.Spec, e.Reason)
+}
+
+func (e *Error) Unwrap() error { return ErrInvalidSpec }
+
+// Dot is one dot of the grid. Row and Col index the underlying lattice;
+// X and Y are its position in lattice units with Y growing downwards.
+type Dot struct {
+	Row int     `json:"row"`
+	Col int     `json:"col"`
+	X   float64 `json:"x"`
+	Y   float64 `json:"y"`
+}
+
+// DotGrid is a parsed dot grid.
+type DotGrid struct {
+	// Spec is the canonical form of the notation, e.g. "1-19-1" or "4x6".
+	Spec string `json:"spec"`
+	Kind Kind   `json:"kind"`
+	// Rows and Cols bound the lattice that Dot.Row and Dot.Col index.
+	Rows int `json:"rows"`
+	Cols int `json:"cols"`
+	// RowCounts is the number of dots in each visual row, top to bottom.
+	RowCounts []int `json:"row_counts"`
+	// Rotated is true when the lattice is turned 45° (rows offset by half a spacing).
+	Rotated bool  `json:"rotated"`
+	Dots    []Dot `json:"dots"`
+}
+
+// Parse parses spec into a DotGrid.
+func Parse(spec string) (*DotGrid, error) {
+	s := strings.ToLower(strings.Join(strings.Fields(spec), ""))
+	s = strings.ReplaceAll(s, "×", "x")
+	if s == "" {
+		return nil, &Error{Spec: spec, Reason: "empty spec"}
+	}
+
+	switch {
+	case strings.Contains(s, "x"):
+		return parseRect(spec, s)
+	case strings.Contains(s, "-"):
+		return parseRows(spec, s)
+	default:
+		n, err := parseCount(spec, s)
+		if err != nil {
+			return nil, err
+		}
+		return rectGrid(spec, strconv.Itoa(n), Square, n, n)
+	}
+}
+
+// Transform maps a lattice point (col, row) to grid coordinates,
+// applying the 45° rotation for rotated grids.
+func (g *DotGrid) Transform(col, row float64) (x, y float64) {
+	if !g.Rotated {
+		return col, row
+	}
+	cx, cy := float64(g.Cols)/2, float64(g.Rows)/2
+	dx, dy := col-cx, row-cy
+	return cx + (dx-dy)*math.Sqrt2/2, cy + (dx+dy)*math.Sqrt2/2
+}
+
+func (g *DotGrid) String() string { return g.Spec }
+
+func parseRect(spec, s string) (*DotGrid, error) {
+	interleaved := strings.HasSuffix(s, "i")
+	parts := strings.Split(strings.TrimSuffix(s, "i"), "x")
+	if len(parts) != 2 {
+		return nil, &Error{Spec: spec, Reason: `expected "RxC"`}
+	}
+	rows, err := parseCount(spec, parts[0])
+	if err != nil {
+		return nil, err
+	}
+	cols, err := parseCount(spec, parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if interleaved {
+		if cols < 2 {
+			return nil, &Error{Spec: spec, Reason: "interleaved grids need at least 2 columns"}
+		}
+		counts := make([]int, rows)
+		for i := range counts {
+			counts[i] = cols - i%2
+		}
+		return rowsGrid(spec, fmt.Sprintf("%dx%di", rows, cols), Interleaved, counts)
+	}
+	if rows == cols {
+		return rectGrid(spec, strconv.Itoa(rows), Square, rows, cols)
+	}
+	return rectGrid(spec, fmt.Sprintf("%dx%d", rows, cols), Rect, rows, cols)
+}
+
+func parseRows(spec, s string) (*DotGrid, error) {
+	parts := strings.Split(s, "-")
+	counts := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := parseCount(spec, p)
+		if err != nil {
+			return nil, err
+		}
+		counts[i] = n
+	}
+
+	// a-b-a with a < b is shorthand for rows stepping by one dot up to b and back down
+	if len(counts) == 3 && counts[0] == counts[2] && counts[0] < counts[1] {
+		a, b := counts[0], counts[1]
+		if b > MaxSide {
+			return nil, &Error{Spec: spec, Reason: fmt.Sprintf("at most %d dots per row", MaxSide)}
+		}
+		counts = counts[:0]
+		for n := a; n < b; n++ {
+			counts = append(counts, n)
+		}
+		for n := b; n >= a; n-- {
+			counts = append(counts, n)
+		}
+		return rowsGrid(spec, fmt.Sprintf("%d-%d-%d", a, b, a), Diamond, counts)
+	}
+
+	kind := Diamond
+	if len(counts) > 1 {
+		alternating := true
+		for i := 2; i < len(counts); i++ {
+			if counts[i] != counts[i-2] {
+				alternating = false
+			}
+		}
+		if alternating && abs(counts[1]-counts[0]) == 1 {
+			kind = Interleaved
+		}
+	}
+	return rowsGrid(spec, s, kind, counts)
+}
+
+// rectGrid builds an axis-aligned rows×cols lattice.
+func rectGrid(spec, canonical string, kind Kind, rows, cols int) (*DotGrid, error) {
+	if rows > MaxSide || cols > MaxSide {
+		return nil, &Error{Spec: spec, Reason: fmt.Sprintf("at most %d rows and columns", MaxSide)}
+	}
+	if rows*cols > MaxDots {
+		return nil, &Error{Spec: spec, Reason: fmt.Sprintf("at most %d dots", MaxDots)}
+	}
+	g := &DotGrid{Spec: canonical, Kind: kind, Rows: rows, Cols: cols}
+	for r := 0; r < rows; r++ {
+		g.RowCounts = append(g.RowCounts, cols)
+		for c := 0; c < cols; c++ {
+			g.addDot(r, c)
+		}
+	}
+	return g, nil
+}
+
+// rowsGrid builds a grid from centred row counts. Consecutive rows must differ
+// either by exactly one dot everywhere (rotated lattice) or by an even number
+// everywhere (axis-aligned lattice).
+func rowsGrid(spec, canonical string, kind Kind, counts []int) (*DotGrid, error) {
+	if len(counts) > 2*MaxSide {
+		return nil, &Error{Spec: spec, Reason: fmt.Sprintf("at most %d rows", 2*MaxSide)}
+	}
+	total, widest := 0, 0
+	for _, n := range counts {
+		total += n
+		widest = max(widest, n)
+	}
+	if widest > MaxSide {
+		return nil, &Error{Spec: spec, Reason: fmt.Sprintf("at most %d dots per row", MaxSide)}
+	}
+	if total > MaxDots {
+		return nil, &Error{Spec: spec, Reason: fmt.Sprintf("at most %d dots", MaxDots)}
+	}
+
+	rotated, aligned := len(counts) > 1, true
+	for i := 1; i < len(counts); i++ {
+		d := abs(counts[i] - counts[i-1])
+		if d != 1 {
+			rotated = false
+		}
+		if d%2 != 0 {
+			aligned = false
+		}
+	}
+	if !rotated && !aligned {
+		return nil, &Error{Spec: spec, Reason: "consecutive rows must all differ by one dot, or all by an even number of dots"}
+	}
+
+	g := &DotGrid{Spec: canonical, Kind: kind, RowCounts: counts, Rotated: rotated}
+	if !rotated {
+		g.Rows, g.Cols = len(counts), widest
+		for r, n := range counts {
+			off := (widest - n) / 2
+			for j := 0; j < n; j++ {
+				g.addDot(r, off+j)
+			}
+		}
+		return g, nil
+	}
+
+	// Visual row i is the anti-diagonal row+col = s, with col-row = d spread
+	// evenly around zero. Starting at s = counts[0]-1 keeps every index >= 0.
+	type cell struct{ row, col int }
+	var cells []cell
+	for i, n := range counts {
+		s := counts[0] - 1 + i
+		for j := 0; j < n; j++ {
+			d := 2*j - (n - 1)
+			cells = append(cells, cell{(s - d) / 2, (s + d) / 2})
+		}
+	}
+	minRow, minCol := cells[0].row, cells[0].col
+	for _, c := range cells {
+		minRow, minCol = min(minRow, c.row), min(minCol, c.col)
+	}
+	for _, c := range cells {
+		g.Rows = max(g.Rows, c.row-minRow+1)
+		g.Cols = max(g.Cols, c.col-minCol+1)
+	}
+	if g.Rows > MaxSide || g.Cols > MaxSide {
+		return nil, &Error{Spec: spec, Reason: fmt.Sprintf("lattice larger than %dx%d", MaxSide, MaxSide)}
+	}
+	for _, c := range cells {
+		g.addDot(c.row-minRow, c.col-minCol)
+	}
+	return g, nil
+}
+
+func (g *DotGrid) addDot(row, col int) {
+	x, y := g.Transform(float64(col)+0.5, float64(row)+0.5)
+	g.Dots = append(g.Dots, Dot{Row: row, Col: col, X: x, Y: y})
+}
+
+func parseCount(spec, s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, &Error{Spec: spec, Reason: fmt.Sprintf("%q is not a number", s)}
+	}
+	if n < 1 {
+		return 0, &Error{Spec: spec, Reason: "dot counts must be at least 1"}
+	}
+	if n > MaxSide*MaxSide {
+		return 0, &Error{Spec: spec, Reason: fmt.Sprintf("%d is too large", n)}
+	}
+	return n, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
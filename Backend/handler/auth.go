@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/ansh0014/KolamApp/auth"
+	"github.com/ansh0014/KolamApp/model"
+	"github.com/ansh0014/KolamApp/repository"
+)
+
+// SignupHandler -> POST /auth/signup
+// Body { email, password, name }. Creates the account and returns a token pair.
+func (s *Server) SignupHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+		Name     string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeBadRequest, "invalid request body")
+		return
+	}
+	email, ok := normalizeEmail(req.Email)
+	if !ok {
+		writeInvalid(w, "email", "invalid email address")
+		return
+	}
+	hash, err := auth.HashPassword(req.Password)
+	if errors.Is(err, auth.ErrWeakPassword) {
+		writeInvalid(w, "password", err.Error())
+		return
+	}
+	if err != nil {
+		writeInternal(w, "failed to create account", fmt.Errorf("hash password: %w", err))
+		return
+	}
+
+	u := &model.User{Email: email, Name: strings.TrimSpace(req.Name), PasswordHash: hash}
+	err = s.Users.Create(r.Context(), u)
+	if errors.Is(err, repository.ErrDuplicate) {
+		writeError(w, CodeConflict, "email already registered")
+		return
+	}
+	if err != nil {
+		writeInternal(w, "failed to create account", fmt.Errorf("create user: %w", err))
+		return
+	}
+	s.writeTokens(w, http.StatusCreated, u)
+}
+
+// unknownUserHash is a bcrypt hash, at bcrypt.DefaultCost, that LoginHandler
+// checks passwords against when no user has the email.
+const unknownUserHash = "$2a$10$kxo/hykm9VLt0S6JjROliepbMYcq3K9zyYJMks4xYqqzjoZ1MRO3y"
+
+// LoginHandler -> POST /auth/login
+// Body { email, password }. Returns a token pair.
+func (s *Server) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeBadRequest, "invalid request body")
+		return
+	}
+	email, _ := normalizeEmail(req.Email)
+	u, err := s.Users.GetByEmail(r.Context(), email)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		writeInternal(w, "failed to log in", fmt.Errorf("get user by email: %w", err))
+		return
+	}
+	hash := unknownUserHash
+	if u != nil {
+		hash = u.PasswordHash
+	}
+	// An unknown email still costs a bcrypt comparison, so the response time
+	// does not tell which emails have accounts.
+	if !auth.CheckPassword(hash, req.Password) || u == nil {
+		writeError(w, CodeInvalidCredentials, "invalid email or password")
+		return
+	}
+	s.writeTokens(w, http.StatusOK, u)
+}
+
+// RefreshHandler -> POST /auth/refresh
+// Body { refresh_token }. Returns a new token pair.
+func (s *Server) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeBadRequest, "invalid request body")
+		return
+	}
+	claims, err := s.Tokens.Verify(req.RefreshToken, auth.Refresh)
+	if err != nil {
+		writeError(w, CodeInvalidCredentials, "invalid refresh token")
+		return
+	}
+	u, err := s.Users.Get(r.Context(), claims.Subject)
+	if errors.Is(err, repository.ErrNotFound) {
+		writeError(w, CodeInvalidCredentials, "invalid refresh token")
+		return
+	}
+	if err != nil {
+		writeInternal(w, "failed to refresh token", fmt.Errorf("get user %s: %w", claims.Subject, err))
+		return
+	}
+	s.writeTokens(w, http.StatusOK, u)
+}
+
+// MeHandler -> GET /auth/me
+// Returns the authenticated user.
+func (s *Server) MeHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, auth.UserFrom(r.Context()))
+}
+
+// writeTokens responds with { user, access_token, refresh_token, token_type, expires_in }.
+func (s *Server) writeTokens(w http.ResponseWriter, status int, u *model.User) {
+	access, exp, err := s.Tokens.Issue(u.ID.Hex(), auth.Access)
+	if err != nil {
+		writeInternal(w, "failed to issue tokens", fmt.Errorf("issue access token: %w", err))
+		return
+	}
+	refresh, _, err := s.Tokens.Issue(u.ID.Hex(), auth.Refresh)
+	if err != nil {
+		writeInternal(w, "failed to issue tokens", fmt.Errorf("issue refresh token: %w", err))
+		return
+	}
+	writeJSONStatus(w, status, map[string]interface{}{
+		"user":          u,
+		"access_token":  access,
+		"refresh_token": refresh,
+		"token_type":    "Bearer",
+		"expires_in":    int(time.Until(exp).Seconds()),
+	})
+}
+
+// normalizeEmail lower-cases and validates a bare email address.
+func normalizeEmail(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s {
+		return s, false
+	}
+	return s, true
+}
+
+// ownerID is the ID recorded as owner of new uploads and generations.
+func ownerID(r *http.Request) string {
+	if u := auth.UserFrom(r.Context()); u != nil {
+		return u.ID.Hex()
+	}
+	return ""
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"regexp"
+)
+
+// Error codes. Every error response is a JSON apiError whose code is one of
+// these; clients should branch on the code and may show the message as is.
+//
+//	bad_request             400  the body or form could not be read
+//	invalid_parameter       400  a value is malformed or out of range; details.field names it
+//	unauthorized            401  no, invalid or expired access token
+//	invalid_credentials     401  wrong email or password, or a bad refresh token
+//	forbidden               403  the request is understood but refused
+//	not_found               404  no such route or resource
+//	method_not_allowed      405  the route exists for other methods; see Allow
+//	conflict                409  the resource already exists
+//	gone                    410  the resource can no longer be produced
+//	payload_too_large       413  the body exceeds the size limit
+//	unsupported_media_type  415  the content type is not accepted
+//	unprocessable           422  the input was read but could not be processed
+//	internal_error          500  something failed on our side; quote request_id
+//	upstream_error          502  a service we depend on failed
+//	unavailable             503  temporarily unable to serve; honour Retry-After
+const (
+	CodeBadRequest           = "bad_request"
+	CodeInvalidParameter     = "invalid_parameter"
+	CodeUnauthorized         = "unauthorized"
+	CodeInvalidCredentials   = "invalid_credentials"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeConflict             = "conflict"
+	CodeGone                 = "gone"
+	CodePayloadTooLarge      = "payload_too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeUnprocessable        = "unprocessable"
+	CodeInternal             = "internal_error"
+	CodeUpstream             = "upstream_error"
+	CodeUnavailable          = "unavailable"
+)
+
+// errorStatus is the HTTP status of each error code.
+var errorStatus = map[string]int{
+	CodeBadRequest:           http.StatusBadRequest,
+	CodeInvalidParameter:     http.StatusBadRequest,
+	CodeUnauthorized:         http.StatusUnauthorized,
+	CodeInvalidCredentials:   http.StatusUnauthorized,
+	CodeForbidden:            http.StatusForbidden,
+	CodeNotFound:             http.StatusNotFound,
+	CodeMethodNotAllowed:     http.StatusMethodNotAllowed,
+	CodeConflict:             http.StatusConflict,
+	CodeGone:                 http.StatusGone,
+	CodePayloadTooLarge:      http.StatusRequestEntityTooLarge,
+	CodeUnsupportedMediaType: http.StatusUnsupportedMediaType,
+	CodeUnprocessable:        http.StatusUnprocessableEntity,
+	CodeInternal:             http.StatusInternalServerError,
+	CodeUpstream:             http.StatusBadGateway,
+	CodeUnavailable:          http.StatusServiceUnavailable,
+}
+
+// apiError is the body of every error response.
+type apiError struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+
+	// cause is logged with the request ID and never sent to the client.
+	cause error
+}
+
+func newError(code, message string) *apiError {
+	return &apiError{Code: code, Message: message}
+}
+
+func (e *apiError) Error() string { return e.Code + ": " + e.Message }
+
+func (e *apiError) withDetails(d interface{}) *apiError {
+	e.Details = d
+	return e
+}
+
+func (e *apiError) withCause(err error) *apiError {
+	e.cause = err
+	return e
+}
+
+// writeAPIError renders e with the status of its code, tagging it with the
+// request ID set by RequestID and logging its cause.
+func writeAPIError(w http.ResponseWriter, e *apiError) {
+	status, ok := errorStatus[e.Code]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	e.RequestID = w.Header().Get(RequestIDHeader)
+	if e.cause != nil {
+		log.Printf("request %s: %s: %v", e.RequestID, e.Message, e.cause)
+	}
+	writeJSONStatus(w, status, e)
+}
+
+// writeError writes an error without details.
+func writeError(w http.ResponseWriter, code, message string) {
+	writeAPIError(w, newError(code, message))
+}
+
+// writeInvalid writes an invalid_parameter error for field.
+func writeInvalid(w http.ResponseWriter, field, message string) {
+	writeAPIError(w, newError(CodeInvalidParameter, message).withDetails(map[string]string{"field": field}))
+}
+
+// writeInternal writes a 500 with a generic message and logs cause.
+func writeInternal(w http.ResponseWriter, message string, cause error) {
+	writeAPIError(w, newError(CodeInternal, message).withCause(cause))
+}
+
+// AuthError renders the errors of the auth middleware; it is an auth.ErrorWriter.
+func AuthError(w http.ResponseWriter, status int, message string) {
+	code := CodeUnauthorized
+	if status != http.StatusUnauthorized {
+		code = CodeInternal
+	}
+	writeError(w, code, message)
+}
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID limits what a client may choose as its own request ID.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID gives every request an ID, reusing the client's X-Request-ID if
+// it is reasonable, and echoes it in the response so errors can be traced in
+// the logs.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			b := make([]byte, 8)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ansh0014/KolamApp/model"
+	"github.com/ansh0014/KolamApp/repository"
+)
+
+// Gallery page sizes.
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	maxTags         = 10
+)
+
+// ImageListHandler -> GET /images
+// Query: owner ("me" for the authenticated user), tag, created_after, created_before, sort (newest|oldest), limit, cursor.
+// Returns { items, next_cursor }; next_cursor is omitted on the last page.
+func (s *Server) ImageListHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := repository.ImageFilter{Tag: q.Get("tag")}
+	var ok bool
+	if f.Owner, ok = ownerFilter(w, r); !ok {
+		return
+	}
+	if f.CreatedAfter, f.CreatedBefore, ok = parseDateRange(w, q); !ok {
+		return
+	}
+	if f.Page, ok = parsePage(w, q); !ok {
+		return
+	}
+	limit := f.Limit
+	f.Limit++ // one extra row tells us whether there is a next page
+
+	items, err := s.Images.List(r.Context(), f)
+	if err != nil {
+		writeInternal(w, "failed to list images", fmt.Errorf("list images: %w", err))
+		return
+	}
+	writeJSON(w, pageResponse(items, limit, func(img model.Image) *repository.Cursor {
+		return repository.CursorAt(img.CreatedAt, img.ID)
+	}))
+}
+
+// KolamListHandler -> GET /kolams
+// Query: owner ("me" for the authenticated user), style, grid, generator, tag, created_after, created_before,
+// sort (newest|oldest), limit, cursor. Returns { items, next_cursor }.
+func (s *Server) KolamListHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := repository.KolamFilter{
+		Style:     q.Get("style"),
+		Generator: q.Get("generator"),
+		Tag:       q.Get("tag"),
+	}
+	var ok bool
+	if f.Owner, ok = ownerFilter(w, r); !ok {
+		return
+	}
+	if spec := q.Get("grid"); spec != "" {
+		g, ok := parseGrid(w, spec)
+		if !ok {
+			return
+		}
+		f.Grid = g.Spec
+	}
+	if f.CreatedAfter, f.CreatedBefore, ok = parseDateRange(w, q); !ok {
+		return
+	}
+	if f.Page, ok = parsePage(w, q); !ok {
+		return
+	}
+	limit := f.Limit
+	f.Limit++
+
+	items, err := s.Kolams.List(r.Context(), f)
+	if err != nil {
+		writeInternal(w, "failed to list kolams", fmt.Errorf("list kolams: %w", err))
+		return
+	}
+	writeJSON(w, pageResponse(items, limit, func(k model.Kolam) *repository.Cursor {
+		return repository.CursorAt(k.CreatedAt, k.ID)
+	}))
+}
+
+// pageResponse builds { items, next_cursor } from a List call that asked for
+// limit+1 rows; the extra row only signals that another page exists.
+func pageResponse[T any](items []T, limit int, cursor func(T) *repository.Cursor) map[string]interface{} {
+	resp := map[string]interface{}{}
+	if len(items) > limit {
+		items = items[:limit]
+		resp["next_cursor"] = cursor(items[limit-1]).String()
+	}
+	if items == nil {
+		items = []T{}
+	}
+	resp["items"] = items
+	return resp
+}
+
+// ownerFilter reads the owner query parameter, resolving "me" to the
+// authenticated user and writing a 401 if there is none.
+func ownerFilter(w http.ResponseWriter, r *http.Request) (string, bool) {
+	owner := r.URL.Query().Get("owner")
+	if owner != "me" {
+		return owner, true
+	}
+	if owner = ownerID(r); owner == "" {
+		writeError(w, CodeUnauthorized, "owner=me requires authentication")
+		return "", false
+	}
+	return owner, true
+}
+
+// parsePage reads limit, sort and cursor, writing a 400 and returning false if any is invalid.
+func parsePage(w http.ResponseWriter, q url.Values) (repository.Page, bool) {
+	p := repository.Page{Limit: defaultPageSize, Sort: repository.SortNewest}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			writeInvalid(w, "limit", "limit must be between 1 and "+strconv.Itoa(maxPageSize))
+			return p, false
+		}
+		p.Limit = n
+	}
+	switch v := q.Get("sort"); v {
+	case "", repository.SortNewest, repository.SortOldest:
+		if v != "" {
+			p.Sort = v
+		}
+	default:
+		writeInvalid(w, "sort", "sort must be newest or oldest")
+		return p, false
+	}
+	if v := q.Get("cursor"); v != "" {
+		c, err := repository.ParseCursor(v)
+		if err != nil {
+			writeInvalid(w, "cursor", "invalid cursor")
+			return p, false
+		}
+		p.After = c
+	}
+	return p, true
+}
+
+// parseDateRange reads created_after and created_before as RFC 3339 timestamps
+// or YYYY-MM-DD dates (UTC midnight).
+func parseDateRange(w http.ResponseWriter, q url.Values) (after, before time.Time, ok bool) {
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"created_after", &after}, {"created_before", &before}} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			t, err = time.Parse(time.DateOnly, v)
+		}
+		if err != nil {
+			writeInvalid(w, p.name, p.name+" must be an RFC 3339 timestamp or YYYY-MM-DD date")
+			return after, before, false
+		}
+		*p.dst = t.UTC()
+	}
+	if !after.IsZero() && !before.IsZero() && !after.Before(before) {
+		writeInvalid(w, "created_after", "created_after must be before created_before")
+		return after, before, false
+	}
+	return after, before, true
+}
+
+// cleanTags lower-cases and trims tags, dropping empties and duplicates.
+func cleanTags(tags []string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] || len(out) == maxTags {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	return out
+}
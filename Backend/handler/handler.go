@@ -1,106 +1,308 @@
 package handler
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
-	
+	"github.com/ansh0014/KolamApp/auth"
+	"github.com/ansh0014/KolamApp/derivative"
+	"github.com/ansh0014/KolamApp/grid"
+	"github.com/ansh0014/KolamApp/jobs"
+	"github.com/ansh0014/KolamApp/kolam"
+	"github.com/ansh0014/KolamApp/ml"
 	"github.com/ansh0014/KolamApp/model"
+	"github.com/ansh0014/KolamApp/proxy"
+	"github.com/ansh0014/KolamApp/repository"
 	"github.com/ansh0014/KolamApp/service"
-)
+	"github.com/ansh0014/KolamApp/storage"
+	"github.com/ansh0014/KolamApp/upload"
 
-const storageDir = "ml_output"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
-// Health handler
-func HealthHandler(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, map[string]string{"status": "ok", "service": "kolam-backend-prototype"})
+// Server holds the dependencies shared by the HTTP handlers.
+type Server struct {
+	Store      storage.BlobStore
+	Images     repository.ImageRepository
+	Kolams     repository.KolamRepository
+	Users      repository.UserRepository
+	Tokens     *auth.Issuer
+	Generator  *service.Generator
+	Jobs       *jobs.Queue
+	Classifier ml.Classifier
+	// Tiles, if set, replaces the native generator's built-in tiles.
+	Tiles    []kolam.Tile
+	Proxy    *proxy.Proxy
+	Uploads  upload.Limits
+	Sessions *upload.Sessions
+	// Checks are the dependencies probed by /readyz.
+	Checks []Check
 }
 
-// Serve images from storageDir
-func ImageServeHandler(w http.ResponseWriter, r *http.Request) {
-	name := strings.TrimPrefix(r.URL.Path, "/images/")
-	if name == "" {
-		http.Error(w, "image name required", http.StatusBadRequest)
+// Cache-Control for served images. Stored images do not change, but a plain
+// URL may come to name a new object (a re-uploaded key, a derivative made
+// later), so only a URL whose ?v= matches the content is cached for good;
+// others are revalidated against their ETag once a day.
+const (
+	immutableCacheControl = "public, max-age=31536000, immutable"
+	imageCacheControl     = "public, max-age=86400"
+)
+
+// ImageServeHandler -> GET /images/{name}?size=thumb|medium|full&v=
+// Serves an image from the blob store. size (default full) picks a derivative;
+// an image with no derivative of that size, because it is already that small
+// or predates derivatives, is served whole. v is the content version that
+// stored URLs carry. Responses have a strong ETag from the content hash and
+// answer HEAD, If-None-Match and Range requests from every storage backend.
+// What is served comes from the image or kolam record; the store is only
+// asked about files no record fully describes.
+func (s *Server) ImageServeHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !storage.ValidKey(name) {
+		writeInvalid(w, "name", "invalid filename")
 		return
 	}
-	if strings.Contains(name, "..") || strings.ContainsRune(name, os.PathSeparator) {
-		http.Error(w, "invalid filename", http.StatusBadRequest)
+	size := r.URL.Query().Get("size")
+	if size == "" {
+		size = derivative.Full
+	}
+	if !derivative.Valid(size) {
+		writeInvalid(w, "size", "size must be thumb, medium or full")
 		return
 	}
-	p := filepath.Join(storageDir, filepath.Clean(name))
-	if _, err := os.Stat(p); os.IsNotExist(err) {
-		http.Error(w, "not found", http.StatusNotFound)
+	obj, err := s.storedObject(r.Context(), name, size)
+	if err != nil {
+		writeInternal(w, "failed to read image", fmt.Errorf("image serve %s: %w", name, err))
+		return
+	}
+	rc, obj, err := storage.Open(r.Context(), s.Store, obj)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeError(w, CodeNotFound, "image not found")
+		return
+	}
+	if err != nil {
+		writeInternal(w, "failed to read image", fmt.Errorf("image serve %s: %w", name, err))
 		return
 	}
-	http.ServeFile(w, r, p)
+	defer rc.Close()
+
+	h := w.Header()
+	h.Set("Content-Type", obj.ContentType)
+	if obj.SHA256 != "" {
+		h.Set("ETag", `"`+obj.SHA256+`"`)
+	}
+	if v := r.URL.Query().Get("v"); v != "" && v == storage.Version(obj) {
+		h.Set("Cache-Control", immutableCacheControl)
+	} else {
+		h.Set("Cache-Control", imageCacheControl)
+	}
+	// ServeContent answers HEAD, conditional and range requests itself
+	http.ServeContent(w, r, obj.Key, obj.ModTime, rc)
 }
 
-// Upload handler: saves file and stores metadata in MongoDB
-// expects multipart form field "file"
-func ImageUploadHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "only POST allowed", http.StatusMethodNotAllowed)
-		return
+// storedObject describes the size rendition of the file name from the image
+// or kolam record that stores it. name may also be a derivative's own file
+// name, which is served as it is. A file with no record is described by its
+// key alone, for storage.Open to look up.
+func (s *Server) storedObject(ctx context.Context, name, size string) (storage.Object, error) {
+	var (
+		filename, url, sum string
+		length             int64
+		created            time.Time
+		derivatives        []model.Derivative
+	)
+	page := repository.Page{Limit: 1}
+	images, err := s.Images.List(ctx, repository.ImageFilter{File: name, Page: page})
+	if err != nil {
+		return storage.Object{}, fmt.Errorf("find image record: %w", err)
+	}
+	if len(images) > 0 {
+		img := images[0]
+		filename, url, sum, length, created, derivatives = img.Filename, img.URL, img.SHA256, img.Bytes, img.CreatedAt, img.Derivatives
+	} else {
+		kolams, err := s.Kolams.List(ctx, repository.KolamFilter{File: name, Page: page})
+		if err != nil {
+			return storage.Object{}, fmt.Errorf("find kolam record: %w", err)
+		}
+		if len(kolams) == 0 {
+			return storage.Object{Key: name}, nil
+		}
+		k := kolams[0]
+		filename, url, sum, length, created, derivatives = k.Filename, k.URL, k.SHA256, k.Bytes, k.CreatedAt, k.Derivatives
 	}
-	if err := r.ParseMultipartForm(32 << 20); err != nil {
-		http.Error(w, "failed parse multipart: "+err.Error(), http.StatusBadRequest)
+
+	for _, d := range derivatives {
+		if d.Filename != name && (name != filename || d.Size != size) {
+			continue
+		}
+		if d.SHA256 == "" {
+			return storage.Object{Key: d.Filename}, nil
+		}
+		return storage.Object{
+			Key:         d.Filename,
+			Size:        d.Bytes,
+			ContentType: d.ContentType,
+			ModTime:     created,
+			SHA256:      d.SHA256,
+			URL:         d.URL,
+		}, nil
+	}
+	if sum == "" || length == 0 {
+		// recorded before hashes and sizes were; only the store knows the rest
+		return storage.Object{Key: filename}, nil
+	}
+	return storage.Object{
+		Key:         filename,
+		Size:        length,
+		ContentType: storage.ContentTypeFor(filename),
+		ModTime:     created,
+		SHA256:      sum,
+		URL:         url,
+	}, nil
+}
+
+// ImageUploadHandler -> POST /upload (authenticated)
+// Saves file to the blob store and stores metadata in the image repository.
+// Expects multipart form field "file" and optional comma-separated "tags".
+// Only PNG, JPEG and GIF images within the upload limits are accepted; the
+// type is judged from the content and the file is stored as <id>.<ext>.
+func (s *Server) ImageUploadHandler(w http.ResponseWriter, r *http.Request) {
+	// room for the other form fields on top of the file itself
+	r.Body = http.MaxBytesReader(w, r.Body, s.Uploads.MaxBytes+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, CodePayloadTooLarge, fmt.Sprintf("file too large, the limit is %d bytes", s.Uploads.MaxBytes))
+			return
+		}
+		writeError(w, CodeBadRequest, "invalid multipart form")
 		return
 	}
+	defer r.MultipartForm.RemoveAll()
 	file, header, err := r.FormFile("file")
 	if err != nil {
-		http.Error(w, "missing file form field 'file': "+err.Error(), http.StatusBadRequest)
+		writeInvalid(w, "file", "missing file form field 'file'")
 		return
 	}
 	defer file.Close()
 
-	if err := os.MkdirAll(storageDir, 0755); err != nil {
-		http.Error(w, "failed create storage: "+err.Error(), http.StatusInternalServerError)
+	info, ok := validateUpload(w, file, header.Size, s.Uploads)
+	if !ok {
 		return
 	}
+	s.storeUpload(w, r, file, info, cleanTags(strings.Split(r.FormValue("tags"), ",")))
+}
 
-	ext := filepath.Ext(header.Filename)
-	if ext == "" {
-		ext = ".png"
+// storeUpload saves a validated upload to the blob store as <id><ext> along
+// with its derivatives, records it in the image repository and writes
+// { url, id, derivatives }. It reports whether the file was stored.
+func (s *Server) storeUpload(w http.ResponseWriter, r *http.Request, file io.Reader, info upload.Info, tags []string) bool {
+	id := primitive.NewObjectID()
+	filename := id.Hex() + info.Ext
+	// validated uploads are small enough to hold, and both the store and
+	// the derivatives need the bytes
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeInternal(w, "failed to read upload", fmt.Errorf("upload %s: %w", filename, err))
+		return false
 	}
-	filename := time.Now().UTC().Format("20060102T150405Z") + "_" + header.Filename
-	dstPath := filepath.Join(storageDir, filename)
-	dst, err := os.Create(dstPath)
+	obj, err := s.Store.Put(r.Context(), filename, bytes.NewReader(data), info.ContentType)
 	if err != nil {
-		http.Error(w, "failed to save file: "+err.Error(), http.StatusInternalServerError)
-		return
+		writeInternal(w, "failed to save file", fmt.Errorf("upload %s: %w", filename, err))
+		return false
 	}
-	defer dst.Close()
-
-	if _, err := io.Copy(dst, file); err != nil {
-		http.Error(w, "failed write file: "+err.Error(), http.StatusInternalServerError)
-		return
+	derivatives, err := derivative.Make(r.Context(), s.Store, filename, data)
+	if err != nil {
+		log.Printf("derivatives of %s: %v", filename, err)
 	}
 
 	// store metadata
 	img := &model.Image{
-		Filename: filename,
-		URL:      "/images/" + filename,
+		ID:          id,
+		Filename:    filename,
+		Width:       info.Width,
+		Height:      info.Height,
+		Bytes:       obj.Size,
+		SHA256:      obj.SHA256,
+		Derivatives: derivatives,
+		URL:         obj.URL,
+		Owner:       ownerID(r),
+		Tags:        tags,
 	}
-	id, err := service.SaveImageMeta(img)
-	if err != nil {
+	if err := s.Images.Create(r.Context(), img); err != nil {
 		log.Printf("warning: failed save metadata: %v", err)
 		// proceed but return warning
 		writeJSON(w, map[string]interface{}{"url": img.URL, "warning": "metadata save failed"})
-		return
+		return true
 	}
 
-	writeJSON(w, map[string]interface{}{"url": img.URL, "id": id})
+	writeJSON(w, map[string]interface{}{"url": img.URL, "id": img.ID, "derivatives": img.Derivatives})
+	return true
 }
 
+// validateUpload checks an uploaded file against lim, writing the error
+// response and returning false if it is rejected.
+func validateUpload(w http.ResponseWriter, file io.ReadSeeker, size int64, lim upload.Limits) (upload.Info, bool) {
+	info, err := upload.Validate(file, size, lim)
+	switch {
+	case err == nil:
+		return info, true
+	case errors.Is(err, upload.ErrTooLarge), errors.Is(err, upload.ErrDimensions):
+		writeError(w, CodePayloadTooLarge, err.Error())
+	case errors.Is(err, upload.ErrUnsupportedType):
+		writeError(w, CodeUnsupportedMediaType, err.Error())
+	case errors.Is(err, upload.ErrCorrupt):
+		writeError(w, CodeUnprocessable, err.Error())
+	default:
+		writeInternal(w, "failed to read upload", err)
+	}
+	return upload.Info{}, false
+}
 
+// parseGrid parses a grid spec, writing a 400 JSON error and returning false if it is invalid.
+func parseGrid(w http.ResponseWriter, spec string) (*grid.DotGrid, bool) {
+	g, err := grid.Parse(spec)
+	if err == nil {
+		return g, true
+	}
+	var gerr *grid.Error
+	if errors.As(err, &gerr) {
+		writeAPIError(w, newError(CodeInvalidParameter, "invalid grid spec: "+gerr.Reason).
+			withDetails(map[string]string{"field": "grid_size", "spec": gerr.Spec, "reason": gerr.Reason}))
+		return nil, false
+	}
+	writeInvalid(w, "grid_size", "invalid grid spec: "+err.Error())
+	return nil, false
+}
 
 func writeJSON(w http.ResponseWriter, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(v)
 }
+
+func writeJSONStatus(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// NotFound answers a request whose path matches no route.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, CodeNotFound, "no route for "+r.URL.Path)
+}
+
+// MethodNotAllowed answers a request whose path exists but not for r.Method.
+// allow lists the methods the path accepts.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request, allow string) {
+	w.Header().Set("Allow", allow)
+	writeAPIError(w, newError(CodeMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path).
+		withDetails(map[string]string{"allow": allow}))
+}
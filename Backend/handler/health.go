@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ansh0014/KolamApp/ml"
+)
+
+// Check is one dependency probed by /readyz. Run may return details to
+// include in the report. A failing Optional check marks the service degraded
+// but still ready.
+type Check struct {
+	Name     string
+	Timeout  time.Duration
+	Optional bool
+	Run      func(ctx context.Context) (details interface{}, err error)
+}
+
+// PingCheck wraps a plain ping function as a Check.
+func PingCheck(name string, timeout time.Duration, ping func(ctx context.Context) error) Check {
+	return Check{Name: name, Timeout: timeout, Run: func(ctx context.Context) (interface{}, error) {
+		return nil, ping(ctx)
+	}}
+}
+
+// MLCheck probes every instance of the ML service. It passes if any instance
+// is healthy and reports each instance and the circuit breaker.
+func MLCheck(c *ml.Client, timeout time.Duration, optional bool) Check {
+	return Check{Name: "ml", Timeout: timeout, Optional: optional, Run: func(ctx context.Context) (interface{}, error) {
+		endpoints := c.Pool.Probe(ctx)
+		details := map[string]interface{}{"endpoints": endpoints, "circuit": c.Breaker.Status()}
+		for _, e := range endpoints {
+			if e.Healthy {
+				return details, nil
+			}
+		}
+		return details, errors.New("no healthy ml service instance")
+	}}
+}
+
+// checkResult is one entry of the /readyz report.
+type checkResult struct {
+	Name      string      `json:"name"`
+	Status    string      `json:"status"`
+	Optional  bool        `json:"optional,omitempty"`
+	LatencyMS float64     `json:"latency_ms"`
+	Error     string      `json:"error,omitempty"`
+	Details   interface{} `json:"details,omitempty"`
+}
+
+// defaultCheckTimeout applies to checks that do not set their own.
+const defaultCheckTimeout = 2 * time.Second
+
+// HealthHandler -> GET /healthz, GET /
+// Liveness: answers as long as the process can serve requests. It checks no dependencies.
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{"status": "ok", "service": "kolam-backend-prototype"})
+}
+
+// ReadyHandler -> GET /readyz
+// Runs every check concurrently, each under its own timeout, and returns
+// { status, checks: [{ name, status, latency_ms, error, details }] }.
+// status is "ready", "degraded" (only optional checks failed) or
+// "unavailable"; the response is 503 when unavailable.
+func (s *Server) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	results := make([]checkResult, len(s.Checks))
+	var wg sync.WaitGroup
+	for i, c := range s.Checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runCheck(r.Context(), c)
+		}()
+	}
+	wg.Wait()
+
+	status, code := "ready", http.StatusOK
+	for _, res := range results {
+		if res.Status == "ok" {
+			continue
+		}
+		if !res.Optional {
+			status, code = "unavailable", http.StatusServiceUnavailable
+			break
+		}
+		status = "degraded"
+	}
+	writeJSONStatus(w, code, map[string]interface{}{"status": status, "checks": results})
+}
+
+func runCheck(ctx context.Context, c Check) checkResult {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	// Run in the background so a check that ignores its context still
+	// cannot hold up the report past its timeout.
+	type outcome struct {
+		details interface{}
+		err     error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		details, err := c.Run(ctx)
+		done <- outcome{details, err}
+	}()
+	var out outcome
+	select {
+	case out = <-done:
+	case <-ctx.Done():
+		out.err = ctx.Err()
+	}
+
+	res := checkResult{
+		Name:      c.Name,
+		Status:    "ok",
+		Optional:  c.Optional,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		Details:   out.details,
+	}
+	if out.err != nil {
+		res.Status, res.Error = "fail", out.err.Error()
+		if errors.Is(out.err, context.DeadlineExceeded) {
+			res.Error = "timed out after " + timeout.String()
+		}
+	}
+	return res
+}
+
+// MLServiceHealthCheckHandler -> GET /ml-health
+// Probes every ML service instance and reports them; healthy if any instance is.
+func (s *Server) MLServiceHealthCheckHandler(w http.ResponseWriter, r *http.Request) {
+	res := runCheck(r.Context(), MLCheck(s.Generator.ML, 10*time.Second, false))
+	code := http.StatusOK
+	if res.Status != "ok" {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSONStatus(w, code, res)
+}
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ansh0014/KolamApp/jobs"
+	"github.com/ansh0014/KolamApp/model"
+	"github.com/ansh0014/KolamApp/repository"
+)
+
+// SubmitGenerateJobHandler -> POST /jobs/generate
+// Takes the same body as /generate-kolam, queues the generation and returns
+// 202 { id, status, status_url } straight away.
+func (s *Server) SubmitGenerateJobHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeGenerateRequest(w, r)
+	if !ok {
+		return
+	}
+	// Reject requests that can only fail before they take a queue slot.
+	if req.Generator != "ml" && req.Generator != "native" {
+		writeInvalid(w, "generator", "unknown generator: "+req.Generator)
+		return
+	}
+
+	job := &model.Job{
+		Grid:      req.Grid.Spec,
+		Style:     req.Style,
+		Generator: req.Generator,
+		Seed:      req.Seed,
+		Tags:      req.Tags,
+		Owner:     req.Owner,
+	}
+	err := s.Jobs.Submit(r.Context(), job)
+	if errors.Is(err, jobs.ErrQueueFull) {
+		w.Header().Set("Retry-After", "30")
+		writeError(w, CodeUnavailable, "too many pending jobs, try again later")
+		return
+	}
+	if err != nil {
+		writeInternal(w, "failed to queue job", fmt.Errorf("submit job: %w", err))
+		return
+	}
+	statusURL := "/v1/jobs/" + job.ID.Hex()
+	w.Header().Set("Location", statusURL)
+	writeJSONStatus(w, http.StatusAccepted, map[string]interface{}{
+		"id":         job.ID,
+		"status":     job.Status,
+		"status_url": statusURL,
+	})
+}
+
+// JobHandler -> GET /jobs/{id}
+// Returns the job with its status and progress. Once done it also carries
+// the resulting kolam record. Jobs are only visible to their owner.
+func (s *Server) JobHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.ownJob(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	writeJSON(w, s.jobResponse(r.Context(), job))
+}
+
+// JobEventsHandler -> GET /jobs/{id}/events
+// Streams the job's progress as Server-Sent Events: queued, then the
+// generating/rendering/uploading/saving stages, dots and stroke events with
+// native geometry in drawing order, and finally done or failed carrying the
+// same body as GET /jobs/{id}. Clients resume with Last-Event-ID.
+func (s *Server) JobEventsHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.ownJob(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	id := job.ID.Hex()
+	last, _ := strconv.Atoi(r.Header.Get("Last-Event-ID"))
+	broker := s.Jobs.Events()
+
+	rc := http.NewResponseController(w)
+	// streams outlive the server's WriteTimeout
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(ev jobs.Event) error {
+		data := ev.Data
+		if j, ok := ev.Data.(*model.Job); ok && ev.Terminal() {
+			data = s.jobResponse(r.Context(), j)
+		}
+		b, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		if ev.ID > 0 {
+			fmt.Fprintf(w, "id: %d\n", ev.ID)
+			last = ev.ID
+		}
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, b)
+		return rc.Flush()
+	}
+
+	if job.Status == model.JobDone || job.Status == model.JobFailed {
+		for _, ev := range broker.History(id, last) {
+			if send(ev) != nil || ev.Terminal() {
+				return
+			}
+		}
+		// history already dropped; answer from the stored job
+		send(jobs.Event{Type: job.Status, Data: job})
+		return
+	}
+
+	defer broker.Watch(id)()
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+	for first := true; ; first = false {
+		evs, more := broker.Since(id, last)
+		if first && len(evs) == 0 && last == 0 {
+			// nothing published yet (e.g. recovered after a restart): report where the job is
+			send(jobs.Event{Type: job.Status, Data: map[string]interface{}{"status": job.Status, "stage": job.Stage, "progress": job.Progress}})
+		}
+		for _, ev := range evs {
+			if send(ev) != nil || ev.Terminal() {
+				return
+			}
+		}
+		if more == nil {
+			return
+		}
+		select {
+		case <-more:
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			if rc.Flush() != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// ownJob loads a job owned by the caller, writing a 404 otherwise.
+func (s *Server) ownJob(w http.ResponseWriter, r *http.Request, id string) (*model.Job, bool) {
+	job, err := s.Jobs.Get(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) || errors.Is(err, repository.ErrInvalidID) || (err == nil && job.Owner != ownerID(r)) {
+		writeError(w, CodeNotFound, "job not found")
+		return nil, false
+	}
+	if err != nil {
+		writeInternal(w, "failed to load job", fmt.Errorf("get job %s: %w", id, err))
+		return nil, false
+	}
+	return job, true
+}
+
+// jobResponse is the job plus, once done, its kolam record.
+func (s *Server) jobResponse(ctx context.Context, job *model.Job) interface{} {
+	resp := struct {
+		*model.Job
+		Kolam *model.Kolam `json:"kolam,omitempty"`
+	}{Job: job}
+	if job.KolamID != "" {
+		var err error
+		if resp.Kolam, err = s.Kolams.Get(ctx, job.KolamID); err != nil {
+			log.Printf("get kolam %s for job %s: %v", job.KolamID, job.ID.Hex(), err)
+		}
+	}
+	return resp
+}
@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ansh0014/KolamApp/kolam"
+	"github.com/ansh0014/KolamApp/render"
+	"github.com/ansh0014/KolamApp/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// KolamsHandler -> GET /kolams/{file}, POST /kolams/{file}
+// {file} is a kolam ID, or an ID with an ".svg" suffix for its SVG rendering.
+// Path patterns cannot match a suffix, so the two are told apart here; only
+// the SVG accepts POST.
+func (s *Server) KolamsHandler(w http.ResponseWriter, r *http.Request) {
+	id, svg := strings.CutSuffix(r.PathValue("file"), ".svg")
+	if svg {
+		s.KolamSVGHandler(w, r, id)
+		return
+	}
+	if r.Method == http.MethodPost {
+		MethodNotAllowed(w, r, "GET, HEAD")
+		return
+	}
+	s.KolamGetHandler(w, r, id)
+}
+
+// KolamGetHandler -> GET /kolams/{id} (via KolamsHandler)
+// Returns the stored model.Kolam record.
+func (s *Server) KolamGetHandler(w http.ResponseWriter, r *http.Request, id string) {
+	k, err := s.Kolams.Get(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) || errors.Is(err, repository.ErrInvalidID) {
+		writeError(w, CodeNotFound, "kolam not found")
+		return
+	}
+	if err != nil {
+		writeInternal(w, "failed to load kolam", fmt.Errorf("get kolam %s: %w", id, err))
+		return
+	}
+	writeJSON(w, k)
+}
+
+// KolamSVGHandler -> GET/POST /kolams/{id}.svg (via KolamsHandler)
+// Re-creates the kolam from a record ID or a pattern ID and renders it as SVG.
+// Render options are read from the query string on GET and from a JSON body on POST.
+func (s *Server) KolamSVGHandler(w http.ResponseWriter, r *http.Request, id string) {
+	opts := render.DefaultOptions()
+	if r.Method == http.MethodPost {
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+				writeError(w, CodeBadRequest, "invalid request body")
+				return
+			}
+		}
+	} else if err := renderOptionsFromQuery(&opts, r.URL.Query()); err != nil {
+		writeError(w, CodeInvalidParameter, err.Error())
+		return
+	}
+	if err := opts.Validate(); err != nil {
+		writeError(w, CodeInvalidParameter, err.Error())
+		return
+	}
+
+	pattern, ok := s.patternFromID(w, r, id)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	// the same ID and options always render the same document
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	if err := render.SVG(w, pattern, opts); err != nil {
+		log.Printf("render svg %s: %v", id, err)
+	}
+}
+
+// KolamGeometryHandler -> POST /api/generate
+// Body { grid_type, style?, seed? }. Generates the kolam in Go and returns
+// { dots, strokes, grid, seed, style, version } with strokes in drawing order, as used
+// by the frontend KolamCanvas.
+func (s *Server) KolamGeometryHandler(w http.ResponseWriter, r *http.Request) {
+
+	var req struct {
+		GridType string `json:"grid_type"`
+		Style    string `json:"style"`
+		Seed     *int64 `json:"seed"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, CodeBadRequest, "invalid request body")
+		return
+	}
+	if req.GridType == "" {
+		req.GridType = "1-19-1"
+	}
+	seed, ok := seedOrNew(w, req.Seed)
+	if !ok {
+		return
+	}
+	g, ok := parseGrid(w, req.GridType)
+	if !ok {
+		return
+	}
+
+	pattern, err := kolam.Generate(kolam.Options{Grid: g, Style: req.Style, Seed: seed, Tiles: s.Tiles})
+	if errors.Is(err, kolam.ErrUnknownStyle) {
+		writeInvalid(w, "style", err.Error())
+		return
+	}
+	if err != nil {
+		writeInternal(w, "native generate failed", fmt.Errorf("generate %s: %w", req.GridType, err))
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"id":      pattern.ID(),
+		"dots":    pattern.Dots,
+		"strokes": kolam.OrderStrokes(pattern.Strokes),
+		"grid":    pattern.Grid,
+		"seed":    pattern.Seed,
+		"style":   pattern.Style,
+		"version": kolam.VersionOf(s.Tiles),
+	})
+}
+
+// seedOrNew validates a requested seed, or picks a random one when seed is nil.
+func seedOrNew(w http.ResponseWriter, seed *int64) (int64, bool) {
+	if seed == nil {
+		return kolam.NewSeed(), true
+	}
+	if err := kolam.CheckSeed(*seed); err != nil {
+		writeInvalid(w, "seed", err.Error())
+		return 0, false
+	}
+	return *seed, true
+}
+
+// patternFromID regenerates a kolam from a stored record ID or from an ID
+// produced by kolam.Pattern.ID. Only natively generated records can be re-created.
+func (s *Server) patternFromID(w http.ResponseWriter, r *http.Request, id string) (*kolam.Pattern, bool) {
+	var spec, style string
+	var seed int64
+	if _, err := primitive.ObjectIDFromHex(id); err == nil {
+		k, err := s.Kolams.Get(r.Context(), id)
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, CodeNotFound, "kolam not found")
+			return nil, false
+		}
+		if err != nil {
+			writeInternal(w, "failed to load kolam", fmt.Errorf("get kolam %s: %w", id, err))
+			return nil, false
+		}
+		if k.Generator != "native" {
+			writeError(w, CodeNotFound, "vector output is only available for natively generated kolams")
+			return nil, false
+		}
+		if k.GeneratorVersion != "" && k.GeneratorVersion != kolam.VersionOf(s.Tiles) {
+			writeError(w, CodeGone, "kolam was made by an older generator and can no longer be re-created")
+			return nil, false
+		}
+		spec, style, seed = k.Grid, k.Style, k.Seed
+	} else {
+		var err error
+		spec, style, seed, err = kolam.ParseID(id)
+		if err != nil {
+			writeError(w, CodeNotFound, "kolam not found")
+			return nil, false
+		}
+	}
+	g, ok := parseGrid(w, spec)
+	if !ok {
+		return nil, false
+	}
+	pattern, err := kolam.Generate(kolam.Options{Grid: g, Style: style, Seed: seed, Tiles: s.Tiles})
+	if errors.Is(err, kolam.ErrUnknownStyle) {
+		writeError(w, CodeNotFound, "kolam not found")
+		return nil, false
+	}
+	if err != nil {
+		writeInternal(w, "native generate failed", fmt.Errorf("generate %s: %w", id, err))
+		return nil, false
+	}
+	return pattern, true
+}
+
+// renderOptionsFromQuery overrides opts with any render parameters present in q.
+func renderOptionsFromQuery(opts *render.Options, q url.Values) error {
+	floats := map[string]*float64{
+		"stroke_width": &opts.StrokeWidth,
+		"dot_radius":   &opts.DotRadius,
+		"padding":      &opts.Padding,
+	}
+	for key, dst := range floats {
+		if v := q.Get(key); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return errors.New(key + " must be a number")
+			}
+			*dst = f
+		}
+	}
+	strs := map[string]*string{
+		"stroke":     &opts.StrokeColor,
+		"dot":        &opts.DotColor,
+		"background": &opts.Background,
+	}
+	for key, dst := range strs {
+		if v := q.Get(key); v != "" {
+			*dst = v
+		}
+	}
+	return nil
+}
@@ -1,197 +1,187 @@
 package handler
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
-	"strings"
+	"regexp"
+	"strconv"
 	"time"
 
 	"github.com/ansh0014/KolamApp/config"
+	"github.com/ansh0014/KolamApp/kolam"
 	"github.com/ansh0014/KolamApp/ml"
-	"github.com/cloudinary/cloudinary-go/v2"
-	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+	"github.com/ansh0014/KolamApp/service"
 )
 
-// Client handles communication with the ML service
-type Client struct {
-	BaseURL    string
-	HTTPClient *http.Client
-}
-
-// NewClient creates a new ML service client
-func NewClient() *Client {
-	mlServiceURL := os.Getenv("ML_SERVICE_URL")
-	if mlServiceURL == "" {
-		mlServiceURL = "http://localhost:8000" // Default URL for local development
-	}
-
-	return &Client{
-		BaseURL: mlServiceURL,
-		HTTPClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-	}
-}
-
-// GenerateKolamRequest represents the request to generate a kolam
-type GenerateKolamRequest struct {
-	GridSize string `json:"grid_size"`
-}
-
-// GenerateKolam calls the ML service to generate a kolam pattern
-func (c *Client) GenerateKolam(gridSize string) (map[string]interface{}, error) {
-	reqBody := GenerateKolamRequest{
-		GridSize: gridSize,
-	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	// Create request to ML service
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/generate", c.BaseURL), bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	// Execute request
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to call ML service: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Check response
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ML service error (status %d): %s", resp.StatusCode, string(body))
-	}
-
-	// Parse response
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	return result, nil
-}
-
 // GenerateKolamHandler -> POST /generate-kolam
-// Uploads generated PNG to Cloudinary and returns { url, public_id, filename }.
-// Does NOT save to MongoDB or local disk.
-func GenerateKolamHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+// Produces a PNG with the selected generator ("ml" calls the ML service, "native"
+// generates and rasterizes in Go), stores it in the blob store and returns
+// { id, url, public_id, filename, seed, generator_version, cached }, plus
+// derivatives when smaller renditions were made. Native results also carry
+// svg_url. Sending back the same seed re-creates the same kolam; cached is
+// true when the image of an identical earlier request was reused. Every
+// generation is saved as a model.Kolam record owned by the authenticated user;
+// id is its record ID. Large grids should use POST /jobs/generate instead.
+func (s *Server) GenerateKolamHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeGenerateRequest(w, r)
+	if !ok {
 		return
 	}
 
-	var req struct {
-		GridSize string `json:"grid_size"`
-		Style    string `json:"style"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
-		return
-	}
-	if req.GridSize == "" {
-		req.GridSize = "1-19-1"
-	}
-	if req.Style == "" {
-		req.Style = "traditional"
-	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
 
-	// get PNG bytes from ML service
-	mlClient := ml.NewClient()
-	imgBytes, filename, err := mlClient.GenerateKolamPNG(req.GridSize, req.Style)
-	if err != nil {
-		http.Error(w, "ml generate failed: "+err.Error(), http.StatusInternalServerError)
+	res, err := s.Generator.Generate(ctx, req, nil)
+	switch {
+	case errors.Is(err, kolam.ErrUnknownStyle), errors.Is(err, service.ErrUnknownGenerator):
+		writeError(w, CodeInvalidParameter, err.Error())
 		return
-	}
-
-	// Init Cloudinary using config (call config.InitCloudinaryConfig() in main.go on startup)
-	var cld *cloudinary.Cloudinary
-	if config.CloudinaryURL != "" {
-		cld, err = cloudinary.NewFromURL(config.CloudinaryURL)
-	} else {
-		cld, err = cloudinary.NewFromParams(config.CloudinaryCloudName, config.CloudinaryAPIKey, config.CloudinaryAPISecret)
-	}
-	if err != nil {
-		http.Error(w, "cloudinary init failed: "+err.Error(), http.StatusInternalServerError)
+	case errors.Is(err, ml.ErrCircuitOpen):
+		mlUnavailable(w, s.Generator.ML)
 		return
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	// build publicID without file extension
-	baseName := strings.TrimSuffix(filename, filepath.Ext(filename)) // removes ".png"
-	publicID := "kolam/" + baseName
-	overwrite := false
-	uploadParams := uploader.UploadParams{
-		PublicID:  publicID,
-		Folder:    "kolam",
-		Overwrite: &overwrite,
-	}
-
-	uploadResp, err := cld.Upload.Upload(ctx, bytes.NewReader(imgBytes), uploadParams)
-	if err != nil {
-		http.Error(w, "cloudinary upload failed: "+err.Error(), http.StatusInternalServerError)
+	case errors.Is(err, service.ErrSaveRecord):
+		log.Printf("warning: %v", err)
+		// proceed but return warning
+		resp := kolamResponse(res)
+		resp["warning"] = "metadata save failed"
+		writeJSON(w, resp)
 		return
-	}
-
-	// verify upload response
-	if uploadResp.SecureURL == "" || uploadResp.PublicID == "" {
-		http.Error(w, "cloudinary upload did not return URL/public_id", http.StatusInternalServerError)
+	case errors.Is(err, service.ErrMLService), errors.Is(err, service.ErrStorage):
+		writeAPIError(w, newError(CodeUpstream, service.PublicMessage(err)).withCause(err))
+		return
+	case errors.Is(err, context.DeadlineExceeded):
+		writeAPIError(w, newError(CodeUnavailable, service.PublicMessage(err)).withCause(err))
+		return
+	case err != nil:
+		writeInternal(w, service.PublicMessage(err), err)
 		return
 	}
 
 	// log result for debugging
-	log.Printf("Cloudinary uploaded: public_id=%s secure_url=%s", uploadResp.PublicID, uploadResp.SecureURL)
+	log.Printf("Stored generated kolam: id=%s url=%s", res.Kolam.ID.Hex(), res.Kolam.URL)
+	writeJSON(w, kolamResponse(res))
+}
 
-	// return Cloudinary info only
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"url":       uploadResp.SecureURL,
-		"public_id": uploadResp.PublicID,
-		"filename":  filename,
-	})
+// mlStyle matches the style names passed on to the ML service besides the
+// native ones. A style ends up in the stored image's file name.
+var mlStyle = regexp.MustCompile(`^[A-Za-z0-9 _-]{1,32}$`)
+
+// decodeGenerateRequest reads the { grid_size, style, generator, seed, tags } body
+// shared by /generate-kolam and /jobs/generate, applying defaults. A random
+// seed is chosen when none is given.
+func decodeGenerateRequest(w http.ResponseWriter, r *http.Request) (service.GenerateRequest, bool) {
+	var body struct {
+		GridSize  string   `json:"grid_size"`
+		Style     string   `json:"style"`
+		Generator string   `json:"generator"`
+		Seed      *int64   `json:"seed"`
+		Tags      []string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, CodeBadRequest, "invalid request body")
+		return service.GenerateRequest{}, false
+	}
+	seed, ok := seedOrNew(w, body.Seed)
+	if !ok {
+		return service.GenerateRequest{}, false
+	}
+	if body.GridSize == "" {
+		body.GridSize = "1-19-1"
+	}
+	if body.Style == "" {
+		body.Style = "traditional"
+	}
+	if body.Generator == "" {
+		body.Generator = config.KolamGenerator
+	}
+	if !kolam.KnownStyle(body.Style) && (body.Generator != "ml" || !mlStyle.MatchString(body.Style)) {
+		writeInvalid(w, "style", kolam.ErrUnknownStyle.Error()+": "+strconv.Quote(body.Style))
+		return service.GenerateRequest{}, false
+	}
+	g, ok := parseGrid(w, body.GridSize)
+	if !ok {
+		return service.GenerateRequest{}, false
+	}
+	return service.GenerateRequest{
+		Grid:      g,
+		Style:     body.Style,
+		Generator: body.Generator,
+		Seed:      seed,
+		Owner:     ownerID(r),
+		Tags:      cleanTags(body.Tags),
+	}, true
 }
 
-// MLServiceHealthCheckHandler -> GET /ml-health
-// Checks the health of the ML service
-func MLServiceHealthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	mlClient := ml.NewClient()
+// kolamResponse is the JSON body describing a finished generation.
+func kolamResponse(res *service.Result) map[string]interface{} {
+	k := res.Kolam
+	resp := map[string]interface{}{
+		"generator":         k.Generator,
+		"generator_version": k.GeneratorVersion,
+		"seed":              k.Seed,
+		"url":               k.URL,
+		"public_id":         k.PublicID,
+		"filename":          k.Filename,
+		"cached":            res.Cached,
+	}
+	if len(k.Derivatives) > 0 {
+		resp["derivatives"] = k.Derivatives
+	}
+	if !k.ID.IsZero() {
+		resp["id"] = k.ID
+		if res.Pattern != nil {
+			resp["svg_url"] = "/v1/kolams/" + k.ID.Hex() + ".svg"
+		}
+	}
+	return resp
+}
 
-	// Try to connect to the ML service
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/health", mlClient.BaseURL), nil)
-	if err != nil {
-		http.Error(w, "Failed to create request: "+err.Error(), http.StatusInternalServerError)
-		return
+// mlUnavailable answers 503 while the ML service circuit is open, telling the
+// client when the next trial call will be let through.
+func mlUnavailable(w http.ResponseWriter, c *ml.Client) {
+	if at := c.Breaker.Status().RetryAt; at != nil {
+		w.Header().Set("Retry-After", strconv.Itoa(max(1, int(time.Until(*at).Seconds()+0.5))))
 	}
+	writeError(w, CodeUnavailable, "ml service unavailable, try again later")
+}
 
-	resp, err := mlClient.HTTPClient.Do(req)
+// ClassifyHandler -> POST /classify
+// expects multipart form field "file" and returns the kolam family, estimated grid size and confidence
+func (s *Server) ClassifyHandler(w http.ResponseWriter, r *http.Request) {
+	// same limits as an upload, so the ML service only sees images we would store
+	r.Body = http.MaxBytesReader(w, r.Body, s.Uploads.MaxBytes+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, CodePayloadTooLarge, fmt.Sprintf("file too large, the limit is %d bytes", s.Uploads.MaxBytes))
+			return
+		}
+		writeError(w, CodeBadRequest, "invalid multipart form")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	file, header, err := r.FormFile("file")
 	if err != nil {
-		http.Error(w, "ML service is not reachable: "+err.Error(), http.StatusServiceUnavailable)
+		writeInvalid(w, "file", "missing file form field 'file'")
 		return
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		http.Error(w, fmt.Sprintf("ML service returned non-OK status: %d", resp.StatusCode), http.StatusServiceUnavailable)
+	defer file.Close()
+	if _, ok := validateUpload(w, file, header.Size, s.Uploads); !ok {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"status": "ML service is connected and healthy",
-	})
+	result, err := s.Classifier.ClassifyKolam(r.Context(), file)
+	if errors.Is(err, ml.ErrCircuitOpen) {
+		mlUnavailable(w, s.Generator.ML)
+		return
+	}
+	if err != nil {
+		writeAPIError(w, newError(CodeUnprocessable, "could not classify the image").withCause(err))
+		return
+	}
+	writeJSON(w, result)
 }
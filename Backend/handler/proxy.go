@@ -1,40 +1,42 @@
 package handler
 
 import (
-	"io"
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
-	"net/url"
-	"strings"
-	"time"
+
+	"github.com/ansh0014/KolamApp/proxy"
 )
 
-// GET /proxy?u=<url-encoded-cloudinary-url>
-func ProxyImageHandler(w http.ResponseWriter, r *http.Request) {
-	u := r.URL.Query().Get("u")
-	if u == "" {
-		http.Error(w, "missing u param", http.StatusBadRequest)
-		return
-	}
-	parsed, err := url.Parse(u)
-	if err != nil || parsed.Host == "" {
-		http.Error(w, "invalid url", http.StatusBadRequest)
-		return
+// ProxyImageHandler -> GET /proxy?u=<url-encoded image url>
+// Fetches an image from an allowed host (Cloudinary by default) for clients
+// that cannot load it directly. Conditional and range requests are honoured,
+// and fetched images are cached on disk.
+func (s *Server) ProxyImageHandler(w http.ResponseWriter, r *http.Request) {
+	u, err := s.Proxy.Check(r.URL.Query().Get("u"))
+	if err == nil {
+		err = s.Proxy.Serve(w, r, u)
 	}
-	if !strings.Contains(parsed.Host, "res.cloudinary.com") {
-		http.Error(w, "only cloudinary host allowed", http.StatusForbidden)
+	if err == nil {
 		return
 	}
 
-	client := &http.Client{Timeout: 20 * time.Second}
-	resp, err := client.Get(u)
-	if err != nil {
-		http.Error(w, "fetch failed: "+err.Error(), http.StatusBadGateway)
-		return
+	var status *proxy.StatusError
+	switch {
+	case errors.Is(err, proxy.ErrBadURL):
+		writeInvalid(w, "u", "u must be an absolute image url")
+	case errors.Is(err, proxy.ErrNotAllowed), errors.Is(err, proxy.ErrBlockedAddress):
+		writeAPIError(w, newError(CodeForbidden, "host not allowed").withCause(err))
+	case errors.As(err, &status) && (status.Code == http.StatusNotFound || status.Code == http.StatusGone):
+		writeError(w, CodeNotFound, "image not found upstream")
+	case errors.Is(err, proxy.ErrTooLarge):
+		writeAPIError(w, newError(CodeUpstream, fmt.Sprintf("image exceeds %d bytes", s.Proxy.MaxBytes)).withCause(err))
+	case errors.Is(err, proxy.ErrNotImage):
+		writeAPIError(w, newError(CodeUpstream, "upstream did not return an image").withCause(err))
+	case errors.Is(err, context.Canceled):
+		// the client went away
+	default:
+		writeAPIError(w, newError(CodeUpstream, "fetch failed").withCause(err))
 	}
-	defer resp.Body.Close()
-
-	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
-	w.Header().Set("Cache-Control", "public, max-age=3600")
-	w.WriteHeader(resp.StatusCode)
-	io.Copy(w, resp.Body)
 }
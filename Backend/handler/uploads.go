@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ansh0014/KolamApp/upload"
+)
+
+// Resumable uploads follow the tus protocol in spirit: the client creates a
+// session for a known size, sends the file in chunks, each tagged with the
+// offset it starts at, asks for the offset after a dropped connection, and
+// finally completes the session to get the same image record as POST /upload.
+
+// uploadOffsetHeader and uploadLengthHeader are tus's names for the received
+// byte count and the total size.
+const (
+	uploadOffsetHeader = "Upload-Offset"
+	uploadLengthHeader = "Upload-Length"
+)
+
+// chunkTimeout bounds reading a single chunk and answering it, in place of
+// the server's much shorter read and write timeouts. The write deadline runs
+// from when the request headers were read, so it has to cover the upload too.
+const chunkTimeout = 5 * time.Minute
+
+// CreateUploadHandler -> POST /uploads (authenticated)
+// Body { size, tags? }. Starts a resumable upload and returns 201
+// { id, size, offset, expires_at, upload_url }.
+func (s *Server) CreateUploadHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Size int64    `json:"size"`
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, CodeBadRequest, "invalid request body")
+		return
+	}
+	if body.Size <= 0 {
+		writeInvalid(w, "size", "size must be a positive number of bytes")
+		return
+	}
+	if body.Size > s.Uploads.MaxBytes {
+		writeError(w, CodePayloadTooLarge, fmt.Sprintf("file too large, the limit is %d bytes", s.Uploads.MaxBytes))
+		return
+	}
+	sess, err := s.Sessions.Create(ownerID(r), body.Size, cleanTags(body.Tags))
+	if errors.Is(err, upload.ErrOwnerLimit) {
+		writeError(w, CodeConflict, err.Error()+"; complete or delete an upload first")
+		return
+	}
+	if err != nil {
+		writeInternal(w, "failed to start upload", err)
+		return
+	}
+	w.Header().Set("Location", uploadURL(sess.ID))
+	writeSessionHeaders(w, sess)
+	writeJSONStatus(w, http.StatusCreated, sessionResponse(sess))
+}
+
+// UploadStatusHandler -> GET /uploads/{id} (authenticated)
+// Reports how much of the upload has been received, in the Upload-Offset
+// header and as { id, size, offset, complete, expires_at }. HEAD returns the
+// headers alone, for resuming after a dropped connection.
+func (s *Server) UploadStatusHandler(w http.ResponseWriter, r *http.Request) {
+	sess, err := s.Sessions.Get(r.PathValue("id"), ownerID(r))
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeSessionHeaders(w, sess)
+	writeJSON(w, sessionResponse(sess))
+}
+
+// UploadChunkHandler -> PATCH /uploads/{id} (authenticated; PUT is accepted too)
+// The body, sent as application/offset+octet-stream, is the next part of the
+// file; the Upload-Offset header must equal the bytes received so far.
+// Returns the new offset. A 409 carries the offset to resume from instead.
+func (s *Server) UploadChunkHandler(w http.ResponseWriter, r *http.Request) {
+	offset, err := strconv.ParseInt(r.Header.Get(uploadOffsetHeader), 10, 64)
+	if err != nil || offset < 0 {
+		writeInvalid(w, uploadOffsetHeader, "Upload-Offset must be the number of bytes already received")
+		return
+	}
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mt != "application/offset+octet-stream" && mt != "application/octet-stream" {
+		writeError(w, CodeUnsupportedMediaType, "chunks must be sent as application/offset+octet-stream")
+		return
+	}
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Now().Add(chunkTimeout))
+	_ = rc.SetWriteDeadline(time.Now().Add(chunkTimeout))
+
+	id := r.PathValue("id")
+	sess, err := s.Sessions.Write(id, ownerID(r), offset, r.Body)
+	if sess != nil {
+		writeSessionHeaders(w, sess)
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, upload.ErrOffsetMismatch):
+		writeAPIError(w, newError(CodeConflict, fmt.Sprintf("upload is at offset %d", sess.Offset)).
+			withDetails(map[string]int64{"offset": sess.Offset}))
+		return
+	case errors.Is(err, upload.ErrTooLarge):
+		writeError(w, CodePayloadTooLarge, err.Error())
+		return
+	case sess != nil:
+		// the client stopped sending; what arrived is kept
+		writeAPIError(w, newError(CodeBadRequest, "chunk was cut short, resume from Upload-Offset").
+			withDetails(map[string]int64{"offset": sess.Offset}).withCause(err))
+		return
+	default:
+		writeSessionError(w, err)
+		return
+	}
+
+	// Reject the wrong kind of file as soon as its first bytes are in,
+	// rather than after the whole of it has been sent.
+	if sniffLen := min(sess.Size, 512); offset < sniffLen && sess.Offset >= sniffLen {
+		head, err := s.Sessions.Head(id, int(sniffLen))
+		if err != nil {
+			writeInternal(w, "failed to read upload", err)
+			return
+		}
+		if _, err := upload.CheckType(head); err != nil {
+			s.discardSession(id, ownerID(r))
+			writeError(w, CodeUnsupportedMediaType, err.Error())
+			return
+		}
+	}
+	writeJSON(w, sessionResponse(sess))
+}
+
+// CompleteUploadHandler -> POST /uploads/{id}/complete (authenticated)
+// Validates the fully received file like POST /upload, stores it and
+// returns the same { url, id }. The session is gone afterwards.
+func (s *Server) CompleteUploadHandler(w http.ResponseWriter, r *http.Request) {
+	id, owner := r.PathValue("id"), ownerID(r)
+	sess, file, release, err := s.Sessions.Open(id, owner)
+	if errors.Is(err, upload.ErrIncomplete) {
+		writeSessionHeaders(w, sess)
+		writeAPIError(w, newError(CodeConflict, err.Error()).
+			withDetails(map[string]int64{"offset": sess.Offset, "size": sess.Size}))
+		return
+	}
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+
+	info, ok := validateUpload(w, file, sess.Size, s.Uploads)
+	if !ok {
+		release()
+		s.discardSession(id, owner)
+		return
+	}
+	stored := s.storeUpload(w, r, file, info, sess.Tags)
+	release()
+	if stored {
+		s.discardSession(id, owner)
+	}
+}
+
+// DeleteUploadHandler -> DELETE /uploads/{id} (authenticated)
+// Abandons an upload and discards what was received.
+func (s *Server) DeleteUploadHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.Sessions.Delete(r.PathValue("id"), ownerID(r)); err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) discardSession(id, owner string) {
+	if err := s.Sessions.Delete(id, owner); err != nil {
+		log.Printf("discard upload %s: %v", id, err)
+	}
+}
+
+func writeSessionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, upload.ErrSessionNotFound):
+		writeError(w, CodeNotFound, "upload not found")
+	case errors.Is(err, upload.ErrSessionBusy):
+		writeError(w, CodeConflict, err.Error())
+	default:
+		writeInternal(w, "upload failed", err)
+	}
+}
+
+func writeSessionHeaders(w http.ResponseWriter, sess *upload.Session) {
+	w.Header().Set(uploadOffsetHeader, strconv.FormatInt(sess.Offset, 10))
+	w.Header().Set(uploadLengthHeader, strconv.FormatInt(sess.Size, 10))
+}
+
+func uploadURL(id string) string { return "/v1/uploads/" + id }
+
+func sessionResponse(sess *upload.Session) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         sess.ID,
+		"size":       sess.Size,
+		"offset":     sess.Offset,
+		"complete":   sess.Complete(),
+		"expires_at": sess.ExpiresAt,
+		"upload_url": uploadURL(sess.ID),
+	}
+}
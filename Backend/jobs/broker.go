@@ -0,0 +1,145 @@
+package jobs
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types published for a job. Stage events use the service.Stage* names.
+const (
+	EventQueued = "queued"
+	EventDots   = "dots"
+	EventStroke = "stroke"
+	EventDone   = "done"
+	EventFailed = "failed"
+)
+
+// Event is one message on a job's stream. IDs count up from 1 per job.
+type Event struct {
+	ID   int
+	Type string
+	Data interface{}
+}
+
+// Terminal reports whether no events follow e.
+func (e Event) Terminal() bool {
+	return e.Type == EventDone || e.Type == EventFailed
+}
+
+// historyRetention is how long a finished job's events stay available for replay.
+const historyRetention = 2 * time.Minute
+
+// Broker keeps each job's events and wakes up readers when new ones arrive.
+// Readers work from the history, so a slow or reconnecting reader never
+// misses an event.
+type Broker struct {
+	mu     sync.Mutex
+	topics map[string]*topic
+}
+
+type topic struct {
+	history []Event
+	// wake is closed and replaced on every publish
+	wake   chan struct{}
+	closed bool
+	// readers counts Watch calls not yet released
+	readers int
+}
+
+// NewBroker returns an empty broker.
+func NewBroker() *Broker {
+	return &Broker{topics: make(map[string]*topic)}
+}
+
+func (b *Broker) topic(jobID string) *topic {
+	t, ok := b.topics[jobID]
+	if !ok {
+		t = &topic{wake: make(chan struct{})}
+		b.topics[jobID] = t
+	}
+	return t
+}
+
+// Publish appends an event to the job's stream. A terminal event closes the
+// stream and its history is dropped after historyRetention.
+func (b *Broker) Publish(jobID, typ string, data interface{}) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.topic(jobID)
+	if t.closed {
+		return
+	}
+	ev := Event{ID: len(t.history) + 1, Type: typ, Data: data}
+	t.history = append(t.history, ev)
+	close(t.wake)
+	if !ev.Terminal() {
+		t.wake = make(chan struct{})
+		return
+	}
+	t.closed = true
+	time.AfterFunc(historyRetention, func() {
+		b.mu.Lock()
+		if b.topics[jobID] == t {
+			delete(b.topics, jobID)
+		}
+		b.mu.Unlock()
+	})
+}
+
+// Watch registers a reader of the job's stream and returns the function that
+// releases it; call Since only in between. A stream that nothing has been
+// published to is dropped when its last reader leaves, so watching a job that
+// is not running here does not leave anything behind.
+func (b *Broker) Watch(jobID string) (release func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.topic(jobID)
+	t.readers++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			t.readers--
+			if t.readers == 0 && len(t.history) == 0 && b.topics[jobID] == t {
+				delete(b.topics, jobID)
+			}
+		})
+	}
+}
+
+// Since returns the job's events after ID after, and a channel that is
+// closed when more are published. The channel is nil once the stream has ended.
+// The caller must hold a Watch on the job.
+func (b *Broker) Since(jobID string, after int) ([]Event, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.topic(jobID)
+	evs := replayAfter(t.history, after)
+	if t.closed {
+		return evs, nil
+	}
+	return evs, t.wake
+}
+
+// History returns the retained events of a job after ID after, without
+// waiting for the job to publish anything.
+func (b *Broker) History(jobID string, after int) []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if t, ok := b.topics[jobID]; ok {
+		return replayAfter(t.history, after)
+	}
+	return nil
+}
+
+func replayAfter(history []Event, after int) []Event {
+	after = max(after, 0)
+	if after >= len(history) {
+		return nil
+	}
+	return append([]Event(nil), history[after:]...)
+}
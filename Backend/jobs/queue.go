@@ -0,0 +1,283 @@
+// Package jobs runs kolam generations in the background on a bounded pool of
+// workers. Job state lives in a repository.JobRepository, so with the Mongo
+// store unfinished jobs are picked up again after a restart.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ansh0014/KolamApp/model"
+	"github.com/ansh0014/KolamApp/repository"
+	"github.com/ansh0014/KolamApp/service"
+)
+
+// ErrQueueFull is returned by Submit when every queue slot is taken.
+var ErrQueueFull = errors.New("job queue is full")
+
+// ErrTooManyAttempts fails a job that was started Options.MaxAttempts times
+// without finishing, such as one that keeps crashing the process.
+var ErrTooManyAttempts = errors.New("job was interrupted too many times")
+
+// loadRetries is how many times a worker tries to load a job before putting
+// it back at the end of the queue, and loadRetryDelay the wait after the first
+// failure, doubling each time.
+const (
+	loadRetries    = 3
+	loadRetryDelay = time.Second
+)
+
+// Runner does the work of one job. It may set result fields on job (KolamID,
+// Warning); the queue persists them along with the final status.
+type Runner func(ctx context.Context, job *model.Job, progress service.Progress) error
+
+// Options configures a Queue.
+type Options struct {
+	Workers int
+	// Size is how many jobs may wait for a worker.
+	Size int
+	// Timeout bounds a single run of a job.
+	Timeout time.Duration
+	// MaxAttempts is how many times a job may be started, counting runs cut
+	// short by a restart, before it is failed. Zero means no limit.
+	MaxAttempts int
+	// Events, if set, receives each job's progress for streaming.
+	Events *Broker
+	// PublicError, if set, turns a failed run's error into the message stored
+	// on the job; the full error is only logged.
+	PublicError func(error) string
+}
+
+// Queue feeds submitted jobs to a fixed number of workers.
+type Queue struct {
+	repo repository.JobRepository
+	run  Runner
+	opts Options
+
+	pending chan string
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewQueue returns a queue that is not yet running; call Start.
+func NewQueue(repo repository.JobRepository, run Runner, opts Options) *Queue {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Queue{
+		repo:    repo,
+		run:     run,
+		opts:    opts,
+		pending: make(chan string, opts.Size),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start re-queues jobs left unfinished by a previous process and starts the workers.
+func (q *Queue) Start(ctx context.Context) error {
+	unfinished, err := q.repo.List(ctx, repository.JobFilter{
+		Statuses: []string{model.JobQueued, model.JobRunning},
+		Page:     repository.Page{Sort: repository.SortOldest},
+	})
+	if err != nil {
+		return fmt.Errorf("list unfinished jobs: %w", err)
+	}
+	if len(unfinished) > 0 {
+		log.Printf("Recovering %d unfinished jobs", len(unfinished))
+	}
+	for i := 0; i < q.opts.Workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+	// Recovered jobs may outnumber the free slots, so feed them without
+	// holding up startup.
+	go func() {
+		for _, j := range unfinished {
+			select {
+			case q.pending <- j.ID.Hex():
+			case <-q.ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Submit stores job as queued and hands it to the workers.
+func (q *Queue) Submit(ctx context.Context, job *model.Job) error {
+	if len(q.pending) == cap(q.pending) {
+		return ErrQueueFull
+	}
+	job.Status = model.JobQueued
+	job.Stage, job.Progress = "", 0
+	if err := q.repo.Create(ctx, job); err != nil {
+		return err
+	}
+	select {
+	case q.pending <- job.ID.Hex():
+		q.opts.Events.Publish(job.ID.Hex(), EventQueued, stageData(job))
+		return nil
+	default:
+		// lost the race for the last slot
+		q.finish(job, ErrQueueFull)
+		return ErrQueueFull
+	}
+}
+
+// Get returns the current state of a job.
+func (q *Queue) Get(ctx context.Context, id string) (*model.Job, error) {
+	return q.repo.Get(ctx, id)
+}
+
+// Shutdown stops the workers and waits for them to return, or for ctx to end.
+// Jobs interrupted mid-run are put back in the queued state.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.cancel()
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) worker() {
+	defer q.wg.Done()
+	for {
+		select {
+		case id := <-q.pending:
+			q.process(id)
+		case <-q.ctx.Done():
+			return
+		}
+	}
+}
+
+func (q *Queue) process(id string) {
+	job, err := q.load(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		log.Printf("job %s: load: %v", id, err)
+		return
+	}
+	if err != nil {
+		log.Printf("job %s: load: %v; requeueing", id, err)
+		q.requeue(id)
+		return
+	}
+	if job.Status != model.JobQueued && job.Status != model.JobRunning {
+		return
+	}
+	if q.opts.MaxAttempts > 0 && job.Attempts >= q.opts.MaxAttempts {
+		q.finish(job, fmt.Errorf("%w (%d attempts)", ErrTooManyAttempts, job.Attempts))
+		return
+	}
+
+	started := time.Now().UTC()
+	job.Status = model.JobRunning
+	job.StartedAt = &started
+	job.Attempts++
+	job.Error = ""
+	q.save(job)
+
+	ctx, cancel := context.WithTimeout(q.ctx, q.opts.Timeout)
+	defer cancel()
+	err = q.run(ctx, job, func(ev service.Event) {
+		switch {
+		case ev.Dots != nil:
+			q.opts.Events.Publish(id, EventDots, map[string]interface{}{"dots": ev.Dots})
+		case ev.Stroke != nil:
+			q.opts.Events.Publish(id, EventStroke, map[string]interface{}{
+				"index":  ev.Index,
+				"total":  ev.Total,
+				"points": ev.Stroke,
+			})
+		default:
+			job.Stage, job.Progress = ev.Stage, ev.Fraction
+			q.save(job)
+			q.opts.Events.Publish(id, ev.Stage, stageData(job))
+		}
+	})
+
+	if q.ctx.Err() != nil {
+		// shutting down: leave the job for the next process
+		job.Status, job.Stage, job.Progress = model.JobQueued, "", 0
+		q.save(job)
+		return
+	}
+	q.finish(job, err)
+}
+
+// load reads job id, retrying with backoff while the repository fails.
+func (q *Queue) load(id string) (*model.Job, error) {
+	delay := loadRetryDelay
+	for try := 1; ; try++ {
+		job, err := q.repo.Get(q.ctx, id)
+		if err == nil || errors.Is(err, repository.ErrNotFound) || try == loadRetries {
+			return job, err
+		}
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-q.ctx.Done():
+			return nil, q.ctx.Err()
+		}
+	}
+}
+
+// requeue puts job id back at the end of the queue without holding up the
+// worker. On shutdown the job simply stays queued for the next process.
+func (q *Queue) requeue(id string) {
+	go func() {
+		select {
+		case q.pending <- id:
+		case <-q.ctx.Done():
+		}
+	}()
+}
+
+// finish records the final status of job.
+func (q *Queue) finish(job *model.Job, err error) {
+	finished := time.Now().UTC()
+	job.FinishedAt = &finished
+	if err != nil {
+		job.Status, job.Error = model.JobFailed, err.Error()
+		if q.opts.PublicError != nil && !errors.Is(err, ErrTooManyAttempts) {
+			job.Error = q.opts.PublicError(err)
+		}
+		log.Printf("job %s failed: %v", job.ID.Hex(), err)
+	} else {
+		job.Status, job.Stage, job.Progress = model.JobDone, "", 1
+	}
+	q.save(job)
+	final := *job
+	q.opts.Events.Publish(job.ID.Hex(), job.Status, &final)
+}
+
+// stageData is the payload of queued and stage events.
+func stageData(job *model.Job) map[string]interface{} {
+	return map[string]interface{}{"status": job.Status, "stage": job.Stage, "progress": job.Progress}
+}
+
+// Events returns the broker jobs publish to, or nil.
+func (q *Queue) Events() *Broker {
+	return q.opts.Events
+}
+
+// save persists job. It uses a fresh context so state is still written when
+// the job's own context has timed out or the queue is shutting down.
+func (q *Queue) save(job *model.Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.repo.Update(ctx, job); err != nil {
+		log.Printf("job %s: save: %v", job.ID.Hex(), err)
+	}
+}
@@ -0,0 +1,172 @@
+// Package kolam generates kolam geometry natively in Go.
+//
+// It follows the same approach as ml_service/models/generator_stub.py: tiles are
+// chosen at random for the top-left quadrant of the dot lattice, mirrored into
+// the other three quadrants, and rotated 45° with the lattice for diamond grids.
+package kolam
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/ansh0014/KolamApp/grid"
+)
+
+// ErrUnknownStyle is returned for a style that has no tile set.
+var ErrUnknownStyle = errors.New("unknown kolam style")
+
+// cornerTiles are the tile IDs allowed in the top-left cell (closed loops around the dot).
+var cornerTiles = []int{1, 3, 6, 12, 13, 16}
+
+// styleTiles limits the random choice to a subset of tile IDs. A nil entry means every tile.
+var styleTiles = map[string][]int{
+	"traditional": nil,
+	"loops":       {1, 3, 6, 12, 13, 16},
+	"flowing":     {2, 4, 5, 7, 8, 9, 10, 11, 14, 15},
+}
+
+// KnownStyle reports whether Generate accepts style.
+func KnownStyle(style string) bool {
+	_, ok := styleTiles[style]
+	return ok
+}
+
+// Options controls Generate.
+type Options struct {
+	Grid  *grid.DotGrid
+	Style string
+	Seed  int64
+	// Tiles overrides DefaultTiles().
+	Tiles []Tile
+}
+
+// Pattern is generated kolam geometry: one dot and one stroke polyline per grid dot,
+// in grid coordinates (see grid.DotGrid).
+type Pattern struct {
+	Grid    string    `json:"grid"`
+	Style   string    `json:"style"`
+	Seed    int64     `json:"seed"`
+	Dots    []Point   `json:"dots"`
+	Strokes [][]Point `json:"strokes"`
+}
+
+// Generate builds a kolam pattern. The same Options always produce the same
+// Pattern: all randomness comes from a math/rand source seeded with opts.Seed,
+// whose sequence is fixed by the Go 1 compatibility promise.
+func Generate(opts Options) (*Pattern, error) {
+	g := opts.Grid
+	if g == nil || len(g.Dots) == 0 {
+		return nil, errors.New("kolam grid has no dots")
+	}
+
+	style := opts.Style
+	if style == "" {
+		style = "traditional"
+	}
+	allowed, ok := styleTiles[style]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStyle, style)
+	}
+
+	tiles := opts.Tiles
+	if tiles == nil {
+		tiles = DefaultTiles()
+	}
+	byID := make(map[int]Tile, len(tiles))
+	for _, t := range tiles {
+		byID[t.ID] = t
+	}
+	choices := pick(byID, allowed, tiles)
+	corners := pick(byID, cornerTiles, choices)
+	if len(choices) == 0 {
+		return nil, errors.New("no kolam tiles loaded")
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+	layout := layoutGrid(g.Rows, g.Cols, rng, choices, corners)
+
+	minX, minY, maxX, maxY := tileBounds(tiles)
+	scale := 1.0 / math.Max(math.Max(maxX-minX, maxY-minY), 1)
+
+	p := &Pattern{Grid: g.Spec, Style: style, Seed: opts.Seed}
+	for _, d := range g.Dots {
+		tile := layout[d.Row][d.Col]
+		stroke := make([]Point, 0, len(tile.Points))
+		for _, pt := range tile.Points {
+			// tile data is y-up; flip into the grid's y-down cell
+			xs := (pt.X - minX) * scale
+			ys := 1 - (pt.Y-minY)*scale
+			if 2*d.Row >= g.Rows {
+				ys = 1 - ys
+			}
+			if 2*d.Col >= g.Cols {
+				xs = 1 - xs
+			}
+			x, y := g.Transform(float64(d.Col)+xs, float64(d.Row)+ys)
+			stroke = append(stroke, Point{x, y})
+		}
+		p.Strokes = append(p.Strokes, stroke)
+		p.Dots = append(p.Dots, Point{d.X, d.Y})
+	}
+	return p, nil
+}
+
+// layoutGrid fills the top-left quadrant of a rows×cols lattice at random and
+// mirrors it horizontally and vertically. Odd sizes keep their middle row and
+// column unmirrored.
+func layoutGrid(rows, cols int, rng *rand.Rand, choices, corners []Tile) [][]Tile {
+	layout := make([][]Tile, rows)
+	for r := range layout {
+		layout[r] = make([]Tile, cols)
+	}
+	halfR, halfC := (rows+1)/2, (cols+1)/2
+	for r := 0; r < halfR; r++ {
+		for c := 0; c < halfC; c++ {
+			if r == 0 && c == 0 && len(corners) > 0 {
+				layout[r][c] = corners[rng.Intn(len(corners))]
+			} else {
+				layout[r][c] = choices[rng.Intn(len(choices))]
+			}
+		}
+	}
+	for r := 0; r < halfR; r++ {
+		for c := halfC; c < cols; c++ {
+			layout[r][c] = layout[r][cols-1-c]
+		}
+	}
+	for r := halfR; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			layout[r][c] = layout[rows-1-r][c]
+		}
+	}
+	return layout
+}
+
+// pick returns the tiles listed in ids, in order, or fallback when ids is nil or matches nothing.
+func pick(byID map[int]Tile, ids []int, fallback []Tile) []Tile {
+	var out []Tile
+	for _, id := range ids {
+		if t, ok := byID[id]; ok {
+			out = append(out, t)
+		}
+	}
+	if len(out) == 0 {
+		return fallback
+	}
+	return out
+}
+
+// tileBounds is the bounding box of every point in every tile, used for global scaling.
+func tileBounds(tiles []Tile) (minX, minY, maxX, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	for _, t := range tiles {
+		for _, pt := range t.Points {
+			minX, maxX = math.Min(minX, pt.X), math.Max(maxX, pt.X)
+			minY, maxY = math.Min(minY, pt.Y), math.Max(maxY, pt.Y)
+		}
+	}
+	return minX, minY, maxX, maxY
+}
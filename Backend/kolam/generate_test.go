@@ -0,0 +1,62 @@
+package kolam_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/ansh0014/KolamApp/grid"
+	"github.com/ansh0014/KolamApp/kolam"
+	"github.com/ansh0014/KolamApp/render"
+)
+
+// generate returns the strokes, as JSON, and the rendered PNG of one generation.
+func generate(t *testing.T, spec, style string, seed int64) (geometry, img []byte) {
+	t.Helper()
+	g, err := grid.Parse(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := kolam.Generate(kolam.Options{Grid: g, Style: style, Seed: seed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	geometry, err = json.Marshal(p.Strokes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := render.PNG(&buf, p, render.DefaultOptions(), render.Size{Pixels: 512}); err != nil {
+		t.Fatal(err)
+	}
+	return geometry, buf.Bytes()
+}
+
+func TestGenerateIsReproducible(t *testing.T) {
+	for _, tt := range []struct {
+		spec, style string
+	}{
+		{"5x5", "traditional"},
+		{"6x4", "loops"},
+		{"1-7-1", "flowing"},
+	} {
+		t.Run(tt.spec+" "+tt.style, func(t *testing.T) {
+			geometry, img := generate(t, tt.spec, tt.style, 42)
+			again, againImg := generate(t, tt.spec, tt.style, 42)
+			if !bytes.Equal(geometry, again) {
+				t.Error("the same seed gave different geometry")
+			}
+			if !bytes.Equal(img, againImg) {
+				t.Error("the same seed gave a different PNG")
+			}
+
+			other, otherImg := generate(t, tt.spec, tt.style, 43)
+			if bytes.Equal(geometry, other) {
+				t.Error("a different seed gave the same geometry")
+			}
+			if bytes.Equal(img, otherImg) {
+				t.Error("a different seed gave the same PNG")
+			}
+		})
+	}
+}
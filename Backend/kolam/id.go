@@ -0,0 +1,26 @@
+package kolam
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ID returns a stable identifier for the pattern, "<grid>_<style>_<seed>".
+// Because generation is deterministic, the ID is enough to re-create the pattern.
+func (p *Pattern) ID() string {
+	return p.Grid + "_" + p.Style + "_" + strconv.FormatInt(p.Seed, 10)
+}
+
+// ParseID splits an ID produced by Pattern.ID.
+func ParseID(id string) (gridSpec, style string, seed int64, err error) {
+	parts := strings.Split(id, "_")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+		return "", "", 0, fmt.Errorf("invalid kolam id %q", id)
+	}
+	seed, err = strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("invalid kolam id %q: bad seed", id)
+	}
+	return parts[0], parts[1], seed, nil
+}
@@ -0,0 +1,75 @@
+package kolam
+
+import "math"
+
+// OrderStrokes returns the strokes in drawing order for animation: starting
+// with the top-most stroke, each next stroke is the one whose nearest end is
+// closest to where the pen stopped, reversed if needed so it starts there.
+// The input is not modified.
+func OrderStrokes(strokes [][]Point) [][]Point {
+	out := make([][]Point, 0, len(strokes))
+	used := make([]bool, len(strokes))
+
+	first := -1
+	for i, s := range strokes {
+		if len(s) == 0 {
+			used[i] = true
+			continue
+		}
+		if first < 0 || topLeft(s[0], strokes[first][0]) {
+			first = i
+		}
+	}
+	if first < 0 {
+		return out
+	}
+	used[first] = true
+	out = append(out, strokes[first])
+	pen := strokes[first][len(strokes[first])-1]
+
+	for len(out) < len(strokes) {
+		best, bestDist, reverse := -1, math.Inf(1), false
+		for i, s := range strokes {
+			if used[i] {
+				continue
+			}
+			if d := dist2(pen, s[0]); d < bestDist {
+				best, bestDist, reverse = i, d, false
+			}
+			if d := dist2(pen, s[len(s)-1]); d < bestDist {
+				best, bestDist, reverse = i, d, true
+			}
+		}
+		if best < 0 {
+			break
+		}
+		used[best] = true
+		s := strokes[best]
+		if reverse {
+			s = reversed(s)
+		}
+		out = append(out, s)
+		pen = s[len(s)-1]
+	}
+	return out
+}
+
+func topLeft(a, b Point) bool {
+	if a.Y != b.Y {
+		return a.Y < b.Y
+	}
+	return a.X < b.X
+}
+
+func dist2(a, b Point) float64 {
+	dx, dy := a.X-b.X, a.Y-b.Y
+	return dx*dx + dy*dy
+}
+
+func reversed(s []Point) []Point {
+	r := make([]Point, len(s))
+	for i, p := range s {
+		r[len(s)-1-i] = p
+	}
+	return r
+}
@@ -0,0 +1,31 @@
+package kolam
+
+import (
+	"errors"
+	"math/rand/v2"
+)
+
+// Version identifies the geometry the native generator produces. Bump it
+// whenever a change alters the pattern generated for an existing
+// (grid, style, seed), so stored results can be told apart.
+const Version = "native-1"
+
+// MaxSeed is the largest accepted seed, 2^53-1, so seeds survive a round trip
+// through JavaScript numbers in the app.
+const MaxSeed = 1<<53 - 1
+
+// ErrInvalidSeed is returned by CheckSeed for seeds outside [0, MaxSeed].
+var ErrInvalidSeed = errors.New("seed must be an integer between 0 and 9007199254740991")
+
+// NewSeed returns a random seed in [0, MaxSeed].
+func NewSeed() int64 {
+	return rand.Int64N(MaxSeed + 1)
+}
+
+// CheckSeed returns ErrInvalidSeed if seed is out of range.
+func CheckSeed(seed int64) error {
+	if seed < 0 || seed > MaxSeed {
+		return ErrInvalidSeed
+	}
+	return nil
+}
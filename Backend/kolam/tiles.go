@@ -0,0 +1,160 @@
+package kolam
+
+import (
+	"bufio"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Point is an (x, y) coordinate in grid units. It encodes to JSON as [x, y].
+type Point struct {
+	X, Y float64
+}
+
+// MarshalJSON encodes p as a two element array rounded to 4 decimals,
+// which is far below anything visible and keeps large patterns compact.
+func (p Point) MarshalJSON() ([]byte, error) {
+	return []byte("[" + coord(p.X) + "," + coord(p.Y) + "]"), nil
+}
+
+func coord(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1e4)/1e4, 'f', -1, 64)
+}
+
+// Tile is one cell pattern: an open or closed polyline drawn around a dot.
+type Tile struct {
+	ID     int
+	Points []Point
+}
+
+//go:embed tiles.txt
+var defaultTileData string
+
+var (
+	defaultTilesOnce sync.Once
+	defaultTiles     []Tile
+)
+
+// DefaultTiles returns the built-in tile set, sorted by ID. It is a small
+// hand-drawn set in the ml_service format, not the kolam_data_numerical.txt
+// that ml_service downloads from KOLAM_DATA_URL, so native kolams use other
+// curves than ML ones unless that file is loaded with LoadTiles and passed
+// in Options.Tiles.
+func DefaultTiles() []Tile {
+	defaultTilesOnce.Do(func() {
+		tiles, err := ParseTiles(strings.NewReader(defaultTileData))
+		if err != nil {
+			panic("kolam: bad embedded tiles.txt: " + err.Error())
+		}
+		defaultTiles = tiles
+	})
+	return defaultTiles
+}
+
+var (
+	patternHeader = regexp.MustCompile(`--- Pattern (\d+) ---`)
+	pointLine     = regexp.MustCompile(`\(x=([-\d.]+),\s*y=([-\d.]+)\)`)
+)
+
+// ParseTiles reads tile data in the ml_service kolam_data_numerical.txt format:
+//
+//	--- Pattern 1 ---
+//	Point 1: (x=0.8000, y=0.5000)
+//
+// Patterns without points are dropped. The result is sorted by ID.
+func ParseTiles(r io.Reader) ([]Tile, error) {
+	var tiles []Tile
+	var cur *Tile
+	flush := func() {
+		if cur != nil && len(cur.Points) > 0 {
+			tiles = append(tiles, *cur)
+		}
+		cur = nil
+	}
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "--- Pattern") {
+			flush()
+			if m := patternHeader.FindStringSubmatch(line); m != nil {
+				id, _ := strconv.Atoi(m[1])
+				cur = &Tile{ID: id}
+			}
+			continue
+		}
+		if cur == nil || !strings.Contains(line, "Point") || !strings.Contains(line, "x=") {
+			continue
+		}
+		m := pointLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		x, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %d: bad x %q", cur.ID, m[1])
+		}
+		y, err := strconv.ParseFloat(m[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %d: bad y %q", cur.ID, m[2])
+		}
+		cur.Points = append(cur.Points, Point{x, y})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read tiles: %w", err)
+	}
+	flush()
+
+	sort.Slice(tiles, func(i, j int) bool { return tiles[i].ID < tiles[j].ID })
+	return tiles, nil
+}
+
+// LoadTiles reads a tile file such as ml_service's kolam_data_numerical.txt.
+func LoadTiles(path string) ([]Tile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open tiles: %w", err)
+	}
+	defer f.Close()
+	tiles, err := ParseTiles(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(tiles) == 0 {
+		return nil, fmt.Errorf("%s: no patterns", path)
+	}
+	return tiles, nil
+}
+
+// VersionOf is the geometry version of kolams drawn with tiles: Version for
+// the default tiles, and Version plus a digest of the tiles otherwise, so
+// results of different tile sets are never mistaken for one another.
+func VersionOf(tiles []Tile) string {
+	if tiles == nil {
+		return Version
+	}
+	h := sha256.New()
+	var b [8]byte
+	for _, t := range tiles {
+		binary.BigEndian.PutUint64(b[:], uint64(t.ID))
+		h.Write(b[:])
+		for _, p := range t.Points {
+			binary.BigEndian.PutUint64(b[:], math.Float64bits(p.X))
+			h.Write(b[:])
+			binary.BigEndian.PutUint64(b[:], math.Float64bits(p.Y))
+			h.Write(b[:])
+		}
+	}
+	return Version + "+tiles-" + hex.EncodeToString(h.Sum(nil))[:8]
+}
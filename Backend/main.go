@@ -9,8 +9,18 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/ansh0014/KolamApp/auth"
 	"github.com/ansh0014/KolamApp/config"
+	"github.com/ansh0014/KolamApp/handler"
+	"github.com/ansh0014/KolamApp/jobs"
+	"github.com/ansh0014/KolamApp/kolam"
+	"github.com/ansh0014/KolamApp/ml"
+	"github.com/ansh0014/KolamApp/proxy"
+	"github.com/ansh0014/KolamApp/repository"
 	"github.com/ansh0014/KolamApp/router"
+	"github.com/ansh0014/KolamApp/service"
+	"github.com/ansh0014/KolamApp/storage"
+	"github.com/ansh0014/KolamApp/upload"
 	"github.com/joho/godotenv"
 )
 
@@ -21,16 +31,134 @@ func main() {
 	}
 
 	// Init services
-	if err := config.InitMongo(); err != nil {
-		log.Fatalf("MongoDB initialization failed: %v", err)
+	config.InitMetadataConfig()
+	var images repository.ImageRepository
+	var kolams repository.KolamRepository
+	var users repository.UserRepository
+	var jobRepo repository.JobRepository
+	switch config.MetadataStore {
+	case "mongo":
+		if err := config.InitMongo(); err != nil {
+			log.Fatalf("MongoDB initialization failed: %v", err)
+		}
+		defer config.CloseMongo()
+		images = repository.NewMongoImageRepository(config.MongoDB.Collection("images"))
+		kolams = repository.NewMongoKolamRepository(config.MongoDB.Collection("kolams"))
+		users = repository.NewMongoUserRepository(config.MongoDB.Collection("users"))
+		jobRepo = repository.NewMongoJobRepository(config.MongoDB.Collection("jobs"))
+	case "memory":
+		log.Println("Using in-memory metadata store; records are lost on restart.")
+		images = repository.NewMemoryImageRepository()
+		kolams = repository.NewMemoryKolamRepository()
+		users = repository.NewMemoryUserRepository()
+		jobRepo = repository.NewMemoryJobRepository()
+	default:
+		log.Fatalf("Unknown METADATA_STORE %q", config.MetadataStore)
 	}
-	defer config.CloseMongo()
 
 	config.InitCloudinaryConfig()
-	if config.CloudinaryURL != "" || config.CloudinaryCloudName != "" {
-		log.Println("Cloudinary configured; uploads will use Cloudinary.")
-	} else {
-		log.Println("Cloudinary not configured; uploads will fail until configured.")
+	config.InitStorageConfig()
+	config.InitGeneratorConfig()
+	store, err := storage.New()
+	if err != nil {
+		log.Fatalf("Blob storage initialization failed: %v", err)
+	}
+	if err := config.InitAuthConfig(); err != nil {
+		log.Fatalf("Auth initialization failed: %v", err)
+	}
+	tokens := &auth.Issuer{Secret: config.AuthSecret, AccessTTL: config.AccessTokenTTL, RefreshTTL: config.RefreshTokenTTL}
+	// one client, so generation and classification share the circuit breaker
+	mlClient := ml.NewClient()
+	probeCtx, stopProbes := context.WithCancel(context.Background())
+	defer stopProbes()
+	mlClient.Pool.Start(probeCtx)
+	log.Printf("ML service instances: %v", mlClient.Pool.URLs())
+	var classifier ml.Classifier = ml.StubClassifier{}
+	if config.KolamClassifier == "ml" {
+		classifier = mlClient
+	}
+	var tiles []kolam.Tile
+	if config.KolamTilesFile != "" {
+		if tiles, err = kolam.LoadTiles(config.KolamTilesFile); err != nil {
+			log.Fatalf("Kolam tiles: %v", err)
+		}
+		log.Printf("Native generator tiles: %d patterns from %s", len(tiles), config.KolamTilesFile)
+	}
+	generator := &service.Generator{Store: store, Kolams: kolams, ML: mlClient, Tiles: tiles}
+	if err := config.InitCacheConfig(); err != nil {
+		log.Fatalf("Generation cache initialization failed: %v", err)
+	}
+	if config.GenerationCache {
+		generator.Cache = service.NewCache(kolams, config.GenerationCacheSize)
+	}
+
+	if err := config.InitJobsConfig(); err != nil {
+		log.Fatalf("Jobs initialization failed: %v", err)
+	}
+	queue := jobs.NewQueue(jobRepo, generator.RunJob, jobs.Options{
+		Workers:     config.JobWorkers,
+		Size:        config.JobQueueSize,
+		Timeout:     config.JobTimeout,
+		MaxAttempts: config.JobMaxAttempts,
+		Events:      jobs.NewBroker(),
+		PublicError: service.PublicMessage,
+	})
+	if err := queue.Start(context.Background()); err != nil {
+		log.Fatalf("Job queue start failed: %v", err)
+	}
+
+	if err := config.InitProxyConfig(); err != nil {
+		log.Fatalf("Proxy initialization failed: %v", err)
+	}
+	allow, err := proxy.ParseAllowList(config.ProxyAllowedHosts)
+	if err != nil {
+		log.Fatalf("Proxy initialization failed: %v", err)
+	}
+	var proxyCache *proxy.DiskCache
+	if config.ProxyCache {
+		if proxyCache, err = proxy.OpenDiskCache(config.ProxyCacheDir, config.ProxyCacheBytes, config.ProxyCacheTTL); err != nil {
+			log.Fatalf("Proxy cache initialization failed: %v", err)
+		}
+	}
+	imageProxy := proxy.New(allow, config.ProxyMaxBytes, proxyCache, false)
+
+	if err := config.InitUploadConfig(); err != nil {
+		log.Fatalf("Upload initialization failed: %v", err)
+	}
+	sessions, err := upload.OpenSessions(config.UploadSessionDir, config.UploadSessionTTL)
+	if err != nil {
+		log.Fatalf("Upload initialization failed: %v", err)
+	}
+	sessions.MaxPerOwner = config.UploadSessionsPerOwner
+	sessions.MaxBytesPerOwner = config.UploadSessionBytesPerOwner
+	sessions.Start(probeCtx, 10*time.Minute)
+
+	checks := []handler.Check{
+		handler.PingCheck("storage", 3*time.Second, store.Ping),
+		handler.MLCheck(mlClient, 3*time.Second, config.KolamGenerator != "ml" && config.KolamClassifier != "ml"),
+	}
+	if config.MetadataStore == "mongo" {
+		checks = append([]handler.Check{handler.PingCheck("mongo", 2*time.Second, config.PingMongo)}, checks...)
+	}
+
+	srv := &handler.Server{
+		Store:      store,
+		Images:     images,
+		Kolams:     kolams,
+		Users:      users,
+		Tokens:     tokens,
+		Generator:  generator,
+		Jobs:       queue,
+		Classifier: classifier,
+		Tiles:      tiles,
+		Checks:     checks,
+		Proxy:      imageProxy,
+		Uploads: upload.Limits{
+			MaxBytes:     config.UploadMaxBytes,
+			MaxDimension: config.UploadMaxDimension,
+			MaxPixels:    config.UploadMaxPixels,
+		},
+		Sessions: sessions,
 	}
 
 	// Get server port from environment or use default
@@ -45,13 +173,7 @@ func main() {
 	addr := host + ":" + port
 
 	// Create server with router and timeouts
-	server := &http.Server{
-		Addr:         addr,
-		Handler:      corsMiddleware(router.New()),
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
-	}
+	server := router.NewServer(addr, corsMiddleware(router.New(srv)))
 
 	// Start server in a goroutine so shutdown can happen gracefully
 	go func() {
@@ -75,11 +197,15 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
+	if err := queue.Shutdown(ctx); err != nil {
+		log.Printf("Job workers did not stop in time: %v", err)
+	}
 
 	log.Println("Server stopped")
 }
 
-// corsMiddleware adds CORS headers to enable ViroReact AR to fetch images
+// corsMiddleware adds CORS headers to enable ViroReact AR to fetch images.
+// Preflight requests are answered by the router, which knows each path's methods.
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		origins := os.Getenv("ALLOW_ORIGINS")
@@ -88,13 +214,8 @@ func corsMiddleware(next http.Handler) http.Handler {
 		}
 
 		w.Header().Set("Access-Control-Allow-Origin", origins)
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Last-Event-ID, X-Request-ID, Upload-Offset, If-None-Match, Range")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, Location, Upload-Offset, Upload-Length, ETag, Content-Range")
 
 		next.ServeHTTP(w, r)
 	})
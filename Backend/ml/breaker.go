@@ -0,0 +1,106 @@
+package ml
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting the ML service while the
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("ml service circuit breaker is open")
+
+// Circuit breaker states.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// Breaker stops calls to the ML service after Threshold consecutive failures.
+// Once Cooldown has passed it lets a single trial call through (half-open);
+// the circuit closes again if that call succeeds and reopens if it fails.
+type Breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	trial    bool // a half-open trial call is in flight
+}
+
+// NewBreaker returns a closed breaker.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{Threshold: threshold, Cooldown: cooldown, state: CircuitClosed}
+}
+
+// BreakerStatus is a snapshot of a Breaker for health reports.
+type BreakerStatus struct {
+	State    string `json:"state"`
+	Failures int    `json:"consecutive_failures"`
+	// RetryAt is when an open circuit will let a trial call through.
+	RetryAt *time.Time `json:"retry_at,omitempty"`
+}
+
+// Allow reports whether a call may go ahead, returning ErrCircuitOpen if not.
+// Every allowed call must be followed by Success, Failure or Abort.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.Cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = CircuitHalfOpen
+		b.trial = true
+		return nil
+	case CircuitHalfOpen:
+		if b.trial {
+			return ErrCircuitOpen
+		}
+		b.trial = true
+		return nil
+	}
+	return nil
+}
+
+// Success records a call that reached a healthy service.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state, b.failures, b.trial = CircuitClosed, 0, false
+}
+
+// Failure records a call that failed because of the service.
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.trial = false
+	if b.state == CircuitHalfOpen || b.failures >= b.Threshold {
+		b.state, b.openedAt = CircuitOpen, time.Now()
+	}
+}
+
+// Abort releases a call that ended without saying anything about the
+// service, because the caller gave up on it.
+func (b *Breaker) Abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// Status returns the breaker's current state.
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := BreakerStatus{State: b.state, Failures: b.failures}
+	if b.state == CircuitOpen {
+		retry := b.openedAt.Add(b.Cooldown).UTC()
+		st.RetryAt = &retry
+	}
+	return st
+}
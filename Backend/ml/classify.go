@@ -0,0 +1,314 @@
+package ml
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// Kolam families reported by classifiers.
+const (
+	FamilyPulli = "pulli" // dots joined or encircled by lines
+	FamilySikku = "sikku" // one continuous line looping around the dots
+	FamilyKambi = "kambi" // line kolam drawn without a visible dot grid
+	FamilyPadi  = "padi"  // geometric step patterns of straight lines
+)
+
+// Label is one family with its score in [0, 1].
+type Label struct {
+	Name  string  `json:"name"`
+	Score float64 `json:"score"`
+}
+
+// Classification is the result of classifying a kolam photo.
+type Classification struct {
+	Family     string  `json:"family"`
+	Confidence float64 `json:"confidence"`
+	// GridSize is the estimated dot grid in grid package notation, empty if no dots were found.
+	GridSize   string  `json:"grid_size,omitempty"`
+	DotCount   int     `json:"dot_count"`
+	Labels     []Label `json:"labels"`
+	Classifier string  `json:"classifier"`
+}
+
+// Classifier labels a kolam image.
+type Classifier interface {
+	ClassifyKolam(ctx context.Context, r io.Reader) (*Classification, error)
+}
+
+// ClassifyKolam posts the image to the ML service /classify endpoint as multipart field "file".
+func (c *Client) ClassifyKolam(ctx context.Context, r io.Reader) (*Classification, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", "kolam")
+	if err != nil {
+		return nil, fmt.Errorf("create form file: %w", err)
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return nil, fmt.Errorf("copy image: %w", err)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("close multipart: %w", err)
+	}
+
+	resp, err := c.do(ctx, call{
+		method:      http.MethodPost,
+		path:        "/classify",
+		body:        body.Bytes(),
+		contentType: mw.FormDataContentType(),
+		accept:      "application/json",
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out Classification
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if out.Classifier == "" {
+		out.Classifier = "ml"
+	}
+	return &out, nil
+}
+
+// StubClassifier is a deterministic heuristic classifier that needs no ML service.
+// It looks at ink coverage, symmetry, straight runs and the number of small
+// round blobs (dots) in a downscaled black-and-white copy of the image.
+type StubClassifier struct{}
+
+// stubSide is the longer side of the working copy of the image.
+const stubSide = 256
+
+func (StubClassifier) ClassifyKolam(ctx context.Context, r io.Reader) (*Classification, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	ink, w, h := inkMask(img)
+
+	var inkCount int
+	for _, v := range ink {
+		if v {
+			inkCount++
+		}
+	}
+	coverage := float64(inkCount) / float64(len(ink))
+	sym := symmetry(ink, w, h)
+	straight := straightness(ink, w, h)
+	dots, strokes := components(ink, w, h)
+
+	dotScore := math.Min(1, float64(dots)/16)
+	loneStroke := 0.0
+	if strokes == 1 {
+		loneStroke = 1
+	} else if strokes == 2 {
+		loneStroke = 0.5
+	}
+	scores := map[string]float64{
+		FamilyPulli: 0.5*dotScore + 0.3*sym + 0.2*(1-loneStroke),
+		FamilySikku: 0.4*dotScore + 0.4*loneStroke + 0.2*sym,
+		FamilyKambi: 0.5*(1-dotScore) + 0.3*sym + 0.2*math.Min(1, coverage*5),
+		FamilyPadi:  0.6*straight + 0.2*sym + 0.2*(1-dotScore),
+	}
+
+	// softmax over the scores so they read as probabilities
+	var labels []Label
+	var total float64
+	for name, s := range scores {
+		e := math.Exp(4 * s)
+		labels = append(labels, Label{Name: name, Score: e})
+		total += e
+	}
+	for i := range labels {
+		labels[i].Score = math.Round(labels[i].Score/total*1000) / 1000
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].Score != labels[j].Score {
+			return labels[i].Score > labels[j].Score
+		}
+		return labels[i].Name < labels[j].Name
+	})
+
+	out := &Classification{
+		Family:     labels[0].Name,
+		Confidence: labels[0].Score,
+		DotCount:   dots,
+		Labels:     labels,
+		Classifier: "stub",
+	}
+	if dots > 0 {
+		out.GridSize = strconv.Itoa(max(1, int(math.Round(math.Sqrt(float64(dots))))))
+	}
+	return out, nil
+}
+
+// inkMask downsamples img to at most stubSide pixels and marks the minority
+// luminance class (the drawn lines, whether chalk on floor or pen on paper).
+func inkMask(img image.Image) ([]bool, int, int) {
+	b := img.Bounds()
+	scale := math.Max(1, float64(max(b.Dx(), b.Dy()))/stubSide)
+	w := max(1, int(float64(b.Dx())/scale))
+	h := max(1, int(float64(b.Dy())/scale))
+
+	lum := make([]float64, w*h)
+	var mean float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sx := b.Min.X + int(float64(x)*scale)
+			sy := b.Min.Y + int(float64(y)*scale)
+			r, g, bl, _ := img.At(sx, sy).RGBA()
+			l := (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)) / 65535
+			lum[y*w+x] = l
+			mean += l
+		}
+	}
+	mean /= float64(len(lum))
+
+	dark := make([]bool, len(lum))
+	var darkCount int
+	for i, l := range lum {
+		if l < mean {
+			dark[i] = true
+			darkCount++
+		}
+	}
+	if darkCount*2 <= len(dark) {
+		return dark, w, h
+	}
+	for i := range dark {
+		dark[i] = !dark[i]
+	}
+	return dark, w, h
+}
+
+// symmetry is the fraction of ink pixels matched by their horizontal and vertical mirror.
+func symmetry(ink []bool, w, h int) float64 {
+	var total, matched int
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if !ink[y*w+x] {
+				continue
+			}
+			total += 2
+			if ink[y*w+(w-1-x)] {
+				matched++
+			}
+			if ink[(h-1-y)*w+x] {
+				matched++
+			}
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(matched) / float64(total)
+}
+
+// straightness is the fraction of ink pixels that sit in horizontal or vertical runs of 8 or more.
+func straightness(ink []bool, w, h int) float64 {
+	const minRun = 8
+	inRun := make([]bool, len(ink))
+	mark := func(idx []int) {
+		run := 0
+		for i, p := range idx {
+			if ink[p] {
+				run++
+			} else {
+				run = 0
+			}
+			if run == minRun {
+				for _, q := range idx[i-minRun+1 : i+1] {
+					inRun[q] = true
+				}
+			} else if run > minRun {
+				inRun[p] = true
+			}
+		}
+	}
+	for y := 0; y < h; y++ {
+		idx := make([]int, w)
+		for x := range idx {
+			idx[x] = y*w + x
+		}
+		mark(idx)
+	}
+	for x := 0; x < w; x++ {
+		idx := make([]int, h)
+		for y := range idx {
+			idx[y] = y*w + x
+		}
+		mark(idx)
+	}
+	var total, straight int
+	for i, v := range ink {
+		if v {
+			total++
+			if inRun[i] {
+				straight++
+			}
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(straight) / float64(total)
+}
+
+// components counts 4-connected ink regions, split into small compact blobs
+// (dots) and larger regions (strokes).
+func components(ink []bool, w, h int) (dots, strokes int) {
+	seen := make([]bool, len(ink))
+	maxDot := max(4, w*h/400)
+	stack := []int{}
+	for start, v := range ink {
+		if !v || seen[start] {
+			continue
+		}
+		seen[start] = true
+		stack = append(stack[:0], start)
+		size := 0
+		minX, minY, maxX, maxY := w, h, 0, 0
+		for len(stack) > 0 {
+			p := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			size++
+			x, y := p%w, p/w
+			minX, maxX = min(minX, x), max(maxX, x)
+			minY, maxY = min(minY, y), max(maxY, y)
+			for _, q := range [4]int{p - 1, p + 1, p - w, p + w} {
+				if q < 0 || q >= len(ink) || seen[q] || !ink[q] {
+					continue
+				}
+				if (q == p-1 && x == 0) || (q == p+1 && x == w-1) {
+					continue
+				}
+				seen[q] = true
+				stack = append(stack, q)
+			}
+		}
+		if size < 2 {
+			continue
+		}
+		bw, bh := maxX-minX+1, maxY-minY+1
+		compact := float64(size) >= 0.5*float64(bw*bh) && max(bw, bh) <= 2*min(bw, bh)
+		if size <= maxDot && compact {
+			dots++
+		} else {
+			strokes++
+		}
+	}
+	return dots, strokes
+}
@@ -1,67 +1,91 @@
 package ml
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
+
+	"github.com/ansh0014/KolamApp/grid"
 )
 
-// Client handles communication with the ML service
+// Client handles communication with the ML service. Calls are spread over
+// the instances in Pool, retried according to Retry and pass through
+// Breaker, which fails them fast while the whole service is down.
 type Client struct {
-	BaseURL    string
+	Pool       *Pool
 	HTTPClient *http.Client
+	Retry      RetryPolicy
+	Breaker    *Breaker
+
+	// version is the generator version from the last generate response
+	version atomic.Pointer[string]
 }
 
-// NewClient creates a new ML service client
+// NewClient creates a new ML service client. ML_SERVICE_URL holds one base
+// URL or a comma-separated list of interchangeable instances. Call
+// Pool.Start to have them health-checked in the background.
 func NewClient() *Client {
 	mlServiceURL := strings.TrimSpace(os.Getenv("ML_SERVICE_URL"))
 	if mlServiceURL == "" {
 		mlServiceURL = "http://localhost:8000"
 	}
+	return NewClientFor(strings.Split(mlServiceURL, ","))
+}
+
+// NewClientFor returns a client for the given instance base URLs with the
+// default retry and circuit breaker settings.
+func NewClientFor(urls []string) *Client {
+	hc := &http.Client{}
 	return &Client{
-		BaseURL: mlServiceURL,
-		HTTPClient: &http.Client{
-			Timeout: 60 * time.Second,
-		},
+		Pool:       NewPool(urls, hc),
+		HTTPClient: hc,
+		Retry:      DefaultRetryPolicy(),
+		Breaker:    NewBreaker(5, 30*time.Second),
 	}
 }
 
-// GenerateKolamImage calls the ML service to generate a kolam image and saves it locally
-func (c *Client) GenerateKolamImage(gridSize, style string) (string, error) {
-	// Prepare request
-	reqBody, err := json.Marshal(map[string]string{
-		"grid_size": gridSize,
-		"style":     style,
-	})
-	if err != nil {
-		return "", fmt.Errorf("marshal request: %w", err)
-	}
+// generateRequest is the /generate body. The Python stub only understands a
+// square side length in grid_size, so the full spec is sent alongside it.
+type generateRequest struct {
+	GridSize string `json:"grid_size"`
+	Grid     string `json:"grid"`
+	Style    string `json:"style"`
+	Seed     int64  `json:"seed"`
+}
 
-	// Create request
-	req, err := http.NewRequest("POST", strings.TrimRight(c.BaseURL, "/")+"/generate", bytes.NewBuffer(reqBody))
-	if err != nil {
-		return "", fmt.Errorf("create request: %w", err)
+func newGenerateRequest(g *grid.DotGrid, style string, seed int64) generateRequest {
+	return generateRequest{
+		GridSize: strconv.Itoa(max(g.Rows, g.Cols)),
+		Grid:     g.Spec,
+		Style:    style,
+		Seed:     seed,
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "image/png")
+}
 
-	// Execute request
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("call ml service: %w", err)
-	}
-	defer resp.Body.Close()
+// VersionHeader is the response header in which the ML service reports the
+// version of its generator.
+const VersionHeader = "X-Generator-Version"
 
-	// Check response
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("ml service returned status %d: %s", resp.StatusCode, string(body))
+// GeneratedImage is a PNG produced by the ML service.
+type GeneratedImage struct {
+	PNG []byte
+	// Version is the service's generator version, empty if it did not say.
+	Version string
+}
+
+// GenerateKolamImage calls the ML service to generate a kolam image and saves it locally
+func (c *Client) GenerateKolamImage(ctx context.Context, g *grid.DotGrid, style string, seed int64) (string, error) {
+	img, err := c.GenerateKolamPNG(ctx, g, style, seed)
+	if err != nil {
+		return "", err
 	}
 
 	// Determine output directory relative to the backend executable directory
@@ -80,78 +104,57 @@ func (c *Client) GenerateKolamImage(gridSize, style string) (string, error) {
 	// Create a filename for the image
 	timestamp := time.Now().Format("20060102_150405")
 	// sanitize inputs
-	safeGrid := strings.ReplaceAll(gridSize, " ", "_")
 	safeStyle := strings.ReplaceAll(style, " ", "_")
-	filename := fmt.Sprintf("kolam_%s_%s_%s.png", safeGrid, safeStyle, timestamp)
-	filePath := filepath.Join(outputDir, filename)
-
-	// Create output file
-	outFile, err := os.Create(filePath)
-	if err != nil {
-		return "", fmt.Errorf("create file: %w", err)
-	}
-	defer outFile.Close()
-
-	// Optionally check content-type
-	contentType := resp.Header.Get("Content-Type")
-	if contentType != "" && !strings.Contains(contentType, "png") {
-		// still copy, but warn
-		fmt.Fprintf(os.Stderr, "warning: ml service content-type=%s\n", contentType)
-	}
-
-	// Copy image data to file
-	_, err = io.Copy(outFile, resp.Body)
-	if err != nil {
+	filename := fmt.Sprintf("kolam_%s_%s_%s.png", g.Spec, safeStyle, timestamp)
+	if err := os.WriteFile(filepath.Join(outputDir, filename), img.PNG, 0644); err != nil {
 		return "", fmt.Errorf("save image: %w", err)
 	}
-
 	return filename, nil
 }
 
-// GenerateKolamPNG calls the ML service and returns PNG bytes and a suggested filename (does NOT save to disk)
-func (c *Client) GenerateKolamPNG(gridSize, style string) ([]byte, string, error) {
-	reqBody, err := json.Marshal(map[string]string{
-		"grid_size": gridSize,
-		"style":     style,
-	})
+// GenerateKolamPNG calls the ML service and returns the PNG (does NOT save to disk).
+// The same grid, style and seed give the same geometry for a given service version.
+func (c *Client) GenerateKolamPNG(ctx context.Context, g *grid.DotGrid, style string, seed int64) (*GeneratedImage, error) {
+	reqBody, err := json.Marshal(newGenerateRequest(g, style, seed))
 	if err != nil {
-		return nil, "", fmt.Errorf("marshal request: %w", err)
+		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", strings.TrimRight(c.BaseURL, "/")+"/generate", bytes.NewBuffer(reqBody))
-	if err != nil {
-		return nil, "", fmt.Errorf("create request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "image/png")
-
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.do(ctx, call{
+		method:      http.MethodPost,
+		path:        "/generate",
+		body:        reqBody,
+		contentType: "application/json",
+		accept:      "image/png",
+	})
 	if err != nil {
-		return nil, "", fmt.Errorf("call ml service: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, "", fmt.Errorf("ml service returned status %d: %s", resp.StatusCode, string(body))
-	}
-
 	ct := resp.Header.Get("Content-Type")
 	if ct == "" || !strings.Contains(strings.ToLower(ct), "png") {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, "", fmt.Errorf("unexpected content-type: %s, body: %s", ct, string(body))
+		return nil, fmt.Errorf("unexpected content-type: %s, body: %s", ct, string(body))
 	}
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, "", fmt.Errorf("read response body: %w", err)
+		return nil, fmt.Errorf("read response body: %w", err)
 	}
 
-	safeGrid := strings.ReplaceAll(gridSize, " ", "_")
-	safeStyle := strings.ReplaceAll(style, " ", "_")
-	filename := fmt.Sprintf("kolam_%s_%s_%d.png", safeGrid, safeStyle, time.Now().Unix())
+	version := resp.Header.Get(VersionHeader)
+	c.version.Store(&version)
+	return &GeneratedImage{PNG: data, Version: version}, nil
+}
 
-	return data, filename, nil
+// Version returns the generator version the ML service reported on its most
+// recent generate response, or "" if it has not been called yet.
+func (c *Client) Version() string {
+	if v := c.version.Load(); v != nil {
+		return *v
+	}
+	return ""
 }
 
 // small helper
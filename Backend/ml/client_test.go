@@ -0,0 +1,106 @@
+package ml_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ansh0014/KolamApp/grid"
+	"github.com/ansh0014/KolamApp/ml"
+	"github.com/ansh0014/KolamApp/ml/mltest"
+)
+
+func TestClientGenerate(t *testing.T) {
+	s := mltest.NewServer()
+	defer s.Close()
+	c := newTestClient(s)
+	g, err := grid.Parse("3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := c.GenerateKolamPNG(context.Background(), g, "traditional", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(img.PNG) == 0 || img.Version != mltest.Version {
+		t.Errorf("got %d bytes, version %q; want a PNG from %q", len(img.PNG), img.Version, mltest.Version)
+	}
+	if c.Version() != mltest.Version {
+		t.Errorf("Version() = %q, want %q", c.Version(), mltest.Version)
+	}
+}
+
+func TestClientRetriesStopAtLimit(t *testing.T) {
+	s := mltest.NewServer()
+	defer s.Close()
+	s.Fail(503)
+	c := newTestClient(s)
+	c.Retry.Attempts = 3
+	c.Breaker = ml.NewBreaker(100, time.Minute)
+
+	_, err := classify(c)
+	var se *ml.StatusError
+	if !errors.As(err, &se) || se.Code != 503 {
+		t.Fatalf("err = %v, want a 503 StatusError", err)
+	}
+	if !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("err = %v, want it to report 3 attempts", err)
+	}
+	if s.Requests() != 3 {
+		t.Errorf("service got %d requests, want 3", s.Requests())
+	}
+}
+
+func TestClientDoesNotRetryClientErrors(t *testing.T) {
+	s := mltest.NewServer()
+	defer s.Close()
+	s.Fail(400)
+	c := newTestClient(s)
+
+	if _, err := classify(c); err == nil {
+		t.Fatal("want an error")
+	}
+	if s.Requests() != 1 {
+		t.Errorf("service got %d requests, want 1", s.Requests())
+	}
+	if st := c.Breaker.Status(); st.State != ml.CircuitClosed || st.Failures != 0 {
+		t.Errorf("breaker = %+v, want closed with no failures", st)
+	}
+}
+
+func TestClientBreakerOpens(t *testing.T) {
+	s := mltest.NewServer()
+	defer s.Close()
+	s.Fail(503)
+	c := newTestClient(s)
+	c.Retry.Attempts = 1
+	c.Breaker = ml.NewBreaker(2, 50*time.Millisecond)
+
+	for i := range 2 {
+		if _, err := classify(c); err == nil || errors.Is(err, ml.ErrCircuitOpen) {
+			t.Fatalf("call %d: err = %v, want the service's failure", i, err)
+		}
+	}
+	if st := c.Breaker.Status(); st.State != ml.CircuitOpen || st.RetryAt == nil {
+		t.Fatalf("breaker = %+v, want open", st)
+	}
+	if _, err := classify(c); !errors.Is(err, ml.ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if s.Requests() != 2 {
+		t.Errorf("service got %d requests while open, want none", s.Requests()-2)
+	}
+
+	// after the cooldown a trial call closes it again
+	s.Recover()
+	time.Sleep(60 * time.Millisecond)
+	if _, err := classify(c); err != nil {
+		t.Fatalf("trial call: %v", err)
+	}
+	if st := c.Breaker.Status(); st.State != ml.CircuitClosed {
+		t.Errorf("breaker = %s after a successful trial, want closed", st.State)
+	}
+}
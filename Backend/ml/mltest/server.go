@@ -0,0 +1,144 @@
+// Package mltest provides a fake ML service for exercising ml.Client: load
+// balancing, retries, failover and the circuit breaker. It speaks the same
+// /health, /generate and /classify endpoints as ml_service.
+package mltest
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ansh0014/KolamApp/ml"
+)
+
+// Version is the generator version the fake reports.
+const Version = "fake-1"
+
+// Server is a running fake ML service. Its zero failure status means healthy.
+type Server struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	status int
+	delay  time.Duration
+
+	requests atomic.Int64
+}
+
+// NewServer starts a healthy fake. Close it when done.
+func NewServer() *Server {
+	s := &Server{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", s.health)
+	mux.HandleFunc("/generate", s.generate)
+	mux.HandleFunc("/classify", s.classify)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Fail makes every endpoint, /health included, answer with status.
+func (s *Server) Fail(status int) {
+	s.mu.Lock()
+	s.status = status
+	s.mu.Unlock()
+}
+
+// Recover undoes Fail.
+func (s *Server) Recover() {
+	s.Fail(0)
+}
+
+// SetDelay makes /generate and /classify take at least d.
+func (s *Server) SetDelay(d time.Duration) {
+	s.mu.Lock()
+	s.delay = d
+	s.mu.Unlock()
+}
+
+// Requests returns how many /generate and /classify calls the fake received.
+func (s *Server) Requests() int64 {
+	return s.requests.Load()
+}
+
+// failing writes the configured failure, if any, and reports whether it did.
+func (s *Server) failing(w http.ResponseWriter) bool {
+	s.mu.Lock()
+	status := s.status
+	s.mu.Unlock()
+	if status == 0 {
+		return false
+	}
+	http.Error(w, "fake ml service failure", status)
+	return true
+}
+
+func (s *Server) wait(r *http.Request) {
+	s.mu.Lock()
+	d := s.delay
+	s.mu.Unlock()
+	select {
+	case <-time.After(d):
+	case <-r.Context().Done():
+	}
+}
+
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	if s.failing(w) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+func (s *Server) generate(w http.ResponseWriter, r *http.Request) {
+	s.requests.Add(1)
+	s.wait(r)
+	if s.failing(w) {
+		return
+	}
+	var body struct {
+		Grid string `json:"grid"`
+		Seed int64  `json:"seed"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	// a small image whose shade depends on the seed, so results are
+	// deterministic and distinguishable
+	img := image.NewGray(image.Rect(0, 0, 8, 8))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(body.Seed)
+	}
+	img.Set(0, 0, color.White)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set(ml.VersionHeader, Version)
+	w.Write(buf.Bytes())
+}
+
+func (s *Server) classify(w http.ResponseWriter, r *http.Request) {
+	s.requests.Add(1)
+	s.wait(r)
+	if s.failing(w) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ml.Classification{
+		Family:     ml.FamilyPulli,
+		Confidence: 1,
+		Labels:     []ml.Label{{Name: ml.FamilyPulli, Score: 1}},
+		Classifier: "fake",
+	})
+}
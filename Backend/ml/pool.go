@@ -0,0 +1,217 @@
+package ml
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoEndpoints is returned when a Pool has no ML service URLs.
+var ErrNoEndpoints = errors.New("no ml service endpoints configured")
+
+// Pool is a set of interchangeable ML service instances. Requests go to the
+// healthy instance with the fewest requests in flight. An instance is ejected
+// after EjectAfter consecutive failures, or a failed probe of its /health
+// endpoint, and comes back when a probe succeeds or EjectFor has passed.
+type Pool struct {
+	EjectAfter    int
+	EjectFor      time.Duration
+	ProbeInterval time.Duration
+	ProbeTimeout  time.Duration
+
+	client    *http.Client
+	endpoints []*endpoint
+	// next rotates the starting point of pick so ties are spread out
+	next atomic.Uint64
+}
+
+type endpoint struct {
+	url         string
+	outstanding atomic.Int64
+
+	mu           sync.Mutex
+	failures     int
+	ejectedUntil time.Time
+	lastErr      string
+}
+
+// EndpointStatus describes one instance of a Pool.
+type EndpointStatus struct {
+	URL         string `json:"url"`
+	Healthy     bool   `json:"healthy"`
+	Outstanding int64  `json:"outstanding"`
+	Failures    int    `json:"consecutive_failures"`
+	LastError   string `json:"last_error,omitempty"`
+}
+
+// NewPool returns a pool over urls that probes them with client.
+func NewPool(urls []string, client *http.Client) *Pool {
+	p := &Pool{
+		EjectAfter:    3,
+		EjectFor:      30 * time.Second,
+		ProbeInterval: 10 * time.Second,
+		ProbeTimeout:  2 * time.Second,
+		client:        client,
+	}
+	for _, u := range urls {
+		if u = strings.TrimRight(strings.TrimSpace(u), "/"); u != "" {
+			p.endpoints = append(p.endpoints, &endpoint{url: u})
+		}
+	}
+	return p
+}
+
+// URLs returns the base URLs of the pool's instances.
+func (p *Pool) URLs() []string {
+	out := make([]string, len(p.endpoints))
+	for i, e := range p.endpoints {
+		out[i] = e.url
+	}
+	return out
+}
+
+// Start probes every instance now and then every ProbeInterval until ctx ends.
+func (p *Pool) Start(ctx context.Context) {
+	go func() {
+		t := time.NewTicker(p.ProbeInterval)
+		defer t.Stop()
+		for {
+			p.Probe(ctx)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Probe checks the /health endpoint of every instance concurrently, updates
+// their health and returns the result.
+func (p *Pool) Probe(ctx context.Context) []EndpointStatus {
+	var wg sync.WaitGroup
+	for _, e := range p.endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.probe(ctx, e); err != nil {
+				if ctx.Err() == nil {
+					e.eject(err, p.EjectFor)
+				}
+				return
+			}
+			e.restore()
+		}()
+	}
+	wg.Wait()
+	return p.Status()
+}
+
+func (p *Pool) probe(ctx context.Context, e *endpoint) error {
+	ctx, cancel := context.WithTimeout(ctx, p.ProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.url+"/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Status returns the current state of every instance.
+func (p *Pool) Status() []EndpointStatus {
+	now := time.Now()
+	out := make([]EndpointStatus, len(p.endpoints))
+	for i, e := range p.endpoints {
+		e.mu.Lock()
+		out[i] = EndpointStatus{
+			URL:         e.url,
+			Healthy:     !now.Before(e.ejectedUntil),
+			Outstanding: e.outstanding.Load(),
+			Failures:    e.failures,
+			LastError:   e.lastErr,
+		}
+		e.mu.Unlock()
+	}
+	return out
+}
+
+// pick returns the healthy instance with the fewest requests in flight,
+// preferring ones not in tried. When every instance is ejected it still
+// returns the least loaded one rather than failing outright.
+func (p *Pool) pick(tried map[*endpoint]bool) (*endpoint, error) {
+	n := len(p.endpoints)
+	if n == 0 {
+		return nil, ErrNoEndpoints
+	}
+	now := time.Now()
+	start := int(p.next.Add(1) % uint64(n))
+	var best *endpoint
+	bestRank := 0
+	for i := range n {
+		e := p.endpoints[(start+i)%n]
+		// rank orders candidates: healthy before ejected, untried before tried
+		rank := 0
+		if !e.healthy(now) {
+			rank += 2
+		}
+		if tried[e] {
+			rank++
+		}
+		if best == nil || rank < bestRank || (rank == bestRank && e.outstanding.Load() < best.outstanding.Load()) {
+			best, bestRank = e, rank
+		}
+	}
+	return best, nil
+}
+
+func (e *endpoint) healthy(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !now.Before(e.ejectedUntil)
+}
+
+// succeeded records a request the instance answered properly.
+func (e *endpoint) succeeded() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures = 0
+	e.lastErr = ""
+}
+
+// failed records a request the instance could not serve, ejecting it after
+// ejectAfter consecutive failures.
+func (e *endpoint) failed(err error, ejectAfter int, ejectFor time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures++
+	e.lastErr = err.Error()
+	if e.failures >= ejectAfter {
+		e.ejectedUntil = time.Now().Add(ejectFor)
+	}
+}
+
+func (e *endpoint) eject(err error, ejectFor time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lastErr = err.Error()
+	e.ejectedUntil = time.Now().Add(ejectFor)
+}
+
+func (e *endpoint) restore() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures, e.lastErr, e.ejectedUntil = 0, "", time.Time{}
+}
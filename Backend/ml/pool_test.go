@@ -0,0 +1,123 @@
+package ml_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ansh0014/KolamApp/ml"
+	"github.com/ansh0014/KolamApp/ml/mltest"
+)
+
+// newTestClient returns a client over the fakes that retries without waiting.
+func newTestClient(servers ...*mltest.Server) *ml.Client {
+	urls := make([]string, len(servers))
+	for i, s := range servers {
+		urls[i] = s.URL
+	}
+	c := ml.NewClientFor(urls)
+	c.Retry.BaseDelay, c.Retry.MaxDelay = 0, 0
+	c.Retry.AttemptTimeout = 2 * time.Second
+	return c
+}
+
+// classify makes one call; the fake does not look at the image.
+func classify(c *ml.Client) (*ml.Classification, error) {
+	return c.ClassifyKolam(context.Background(), strings.NewReader("image"))
+}
+
+func status(t *testing.T, p *ml.Pool, url string) ml.EndpointStatus {
+	t.Helper()
+	for _, st := range p.Status() {
+		if st.URL == url {
+			return st
+		}
+	}
+	t.Fatalf("no pool status for %s", url)
+	return ml.EndpointStatus{}
+}
+
+func TestPoolFailsOverFromDownInstance(t *testing.T) {
+	down, up := mltest.NewServer(), mltest.NewServer()
+	defer down.Close()
+	defer up.Close()
+	down.Fail(503)
+	c := newTestClient(down, up)
+
+	for i := range 10 {
+		if _, err := classify(c); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	if st := status(t, c.Pool, down.URL); st.Healthy {
+		t.Errorf("failing instance still healthy after 10 calls: %+v", st)
+	}
+	if st := status(t, c.Pool, up.URL); !st.Healthy || st.Failures != 0 {
+		t.Errorf("working instance status = %+v, want healthy", st)
+	}
+
+	// once ejected, the failing instance gets no more traffic
+	before := down.Requests()
+	for i := range 5 {
+		if _, err := classify(c); err != nil {
+			t.Fatalf("call %d after ejection: %v", i, err)
+		}
+	}
+	if n := down.Requests() - before; n != 0 {
+		t.Errorf("ejected instance got %d requests", n)
+	}
+	if c.Breaker.Status().State != ml.CircuitClosed {
+		t.Errorf("breaker = %s, want closed while one instance works", c.Breaker.Status().State)
+	}
+}
+
+func TestPoolFailsOverFromSlowInstance(t *testing.T) {
+	slow, fast := mltest.NewServer(), mltest.NewServer()
+	defer slow.Close()
+	defer fast.Close()
+	slow.SetDelay(time.Second)
+	c := newTestClient(slow, fast)
+	c.Retry.AttemptTimeout = 100 * time.Millisecond
+
+	for i := range 4 {
+		start := time.Now()
+		if _, err := classify(c); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		if d := time.Since(start); d > 500*time.Millisecond {
+			t.Errorf("call %d took %s, want it moved off the slow instance", i, d)
+		}
+	}
+	if fast.Requests() != 4 {
+		t.Errorf("fast instance served %d calls, want 4", fast.Requests())
+	}
+}
+
+func TestPoolProbeEjectsAndRestores(t *testing.T) {
+	a, b := mltest.NewServer(), mltest.NewServer()
+	defer a.Close()
+	defer b.Close()
+	c := newTestClient(a, b)
+
+	a.Fail(500)
+	c.Pool.Probe(context.Background())
+	if status(t, c.Pool, a.URL).Healthy {
+		t.Error("instance failing /health still healthy after probe")
+	}
+	if !status(t, c.Pool, b.URL).Healthy {
+		t.Error("working instance ejected by probe")
+	}
+	if _, err := classify(c); err != nil {
+		t.Fatal(err)
+	}
+	if a.Requests() != 0 {
+		t.Errorf("probed-out instance got %d requests", a.Requests())
+	}
+
+	a.Recover()
+	c.Pool.Probe(context.Background())
+	if st := status(t, c.Pool, a.URL); !st.Healthy || st.LastError != "" {
+		t.Errorf("recovered instance status = %+v, want healthy", st)
+	}
+}
@@ -0,0 +1,183 @@
+package ml
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how a Client retries failed calls. Every ML service
+// endpoint is a pure function of its input (generation is seeded), so any
+// call may safely be sent again.
+type RetryPolicy struct {
+	// Attempts is the total number of tries, including the first.
+	Attempts int
+	// BaseDelay is the backoff ceiling before the first retry; it doubles for
+	// each further retry up to MaxDelay. The actual wait is random below it.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// AttemptTimeout bounds a single try. The caller's context bounds them all.
+	AttemptTimeout time.Duration
+}
+
+// DefaultRetryPolicy is used by NewClient.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Attempts:       3,
+		BaseDelay:      250 * time.Millisecond,
+		MaxDelay:       4 * time.Second,
+		AttemptTimeout: 60 * time.Second,
+	}
+}
+
+// backoff returns the full-jitter wait before retry n (counting from 1).
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := p.BaseDelay << (n - 1)
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d)
+}
+
+// StatusError is returned when the ML service answers with an unexpected status.
+type StatusError struct {
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("ml service returned status %d: %s", e.Code, e.Body)
+}
+
+// retryable reports whether a response status is worth another try.
+func retryable(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// call is one request to the ML service.
+type call struct {
+	method, path string
+	body         []byte
+	contentType  string
+	accept       string
+}
+
+// do sends c, retrying transport errors and retryable statuses with jittered
+// exponential backoff while the circuit breaker allows it. Each retry goes to
+// an instance not yet tried for this call, if there is one. It returns a 200
+// response, which the caller must close.
+func (cl *Client) do(ctx context.Context, c call) (*http.Response, error) {
+	var lastErr error
+	tried := make(map[*endpoint]bool)
+	for attempt := 1; ; attempt++ {
+		ep, err := cl.Pool.pick(tried)
+		if err != nil {
+			return nil, err
+		}
+		tried[ep] = true
+		if err := cl.Breaker.Allow(); err != nil {
+			if lastErr != nil {
+				return nil, fmt.Errorf("%w (last error: %w)", err, lastErr)
+			}
+			return nil, err
+		}
+		resp, err := cl.attempt(ctx, ep, c)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			cl.Breaker.Abort()
+			return nil, ctx.Err()
+		case err != nil:
+			lastErr = fmt.Errorf("call ml service %s: %w", ep.url, err)
+			cl.Breaker.Failure()
+			ep.failed(lastErr, cl.Pool.EjectAfter, cl.Pool.EjectFor)
+		case resp.StatusCode == http.StatusOK:
+			cl.Breaker.Success()
+			ep.succeeded()
+			return resp, nil
+		default:
+			b, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+			resp.Body.Close()
+			lastErr = &StatusError{Code: resp.StatusCode, Body: string(b)}
+			if resp.StatusCode >= 500 {
+				cl.Breaker.Failure()
+				ep.failed(lastErr, cl.Pool.EjectAfter, cl.Pool.EjectFor)
+			} else {
+				// the service is up; it just did not like this request
+				cl.Breaker.Success()
+				ep.succeeded()
+			}
+			if !retryable(resp.StatusCode) {
+				return nil, lastErr
+			}
+		}
+
+		if attempt >= cl.Retry.Attempts {
+			return nil, fmt.Errorf("%w (after %d attempts)", lastErr, attempt)
+		}
+		t := time.NewTimer(cl.Retry.backoff(attempt))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// attempt makes a single try of c at ep under the per-attempt timeout. The
+// request counts as outstanding on ep until its response body is closed.
+func (cl *Client) attempt(ctx context.Context, ep *endpoint, c call) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, cl.Retry.AttemptTimeout)
+	req, err := http.NewRequestWithContext(ctx, c.method, ep.url+c.path, bytes.NewReader(c.body))
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	ep.outstanding.Add(1)
+	release := func() {
+		cancel()
+		ep.outstanding.Add(-1)
+	}
+	if c.contentType != "" {
+		req.Header.Set("Content-Type", c.contentType)
+	}
+	if c.accept != "" {
+		req.Header.Set("Accept", c.accept)
+	}
+	resp, err := cl.HTTPClient.Do(req)
+	if err != nil {
+		release()
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("attempt timed out after %s", cl.Retry.AttemptTimeout)
+		}
+		return nil, err
+	}
+	// the attempt's context must live until the body has been read
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
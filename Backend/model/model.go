@@ -1,13 +1,101 @@
-
 package model
 
-import "time"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type Image struct {
-    ID        interface{} `bson:"_id,omitempty" json:"id"`
-    Filename  string      `bson:"filename" json:"filename"`
-    URL       string      `bson:"url" json:"url"`
-    Width     int         `bson:"width,omitempty" json:"width,omitempty"`
-    Height    int         `bson:"height,omitempty" json:"height,omitempty"`
-    CreatedAt time.Time   `bson:"created_at" json:"created_at"`
-}
\ No newline at end of file
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Filename    string             `bson:"filename" json:"filename"`
+	URL         string             `bson:"url" json:"url"`
+	Width       int                `bson:"width,omitempty" json:"width,omitempty"`
+	Height      int                `bson:"height,omitempty" json:"height,omitempty"`
+	Bytes       int64              `bson:"bytes,omitempty" json:"bytes,omitempty"`
+	SHA256      string             `bson:"sha256,omitempty" json:"sha256,omitempty"`
+	Derivatives []Derivative       `bson:"derivatives,omitempty" json:"derivatives,omitempty"`
+	Owner       string             `bson:"owner,omitempty" json:"owner,omitempty"`
+	Tags        []string           `bson:"tags,omitempty" json:"tags,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Derivative is a downscaled copy of a stored image, kept alongside it in the
+// blob store. Size names it ("thumb" or "medium"); the original is "full".
+type Derivative struct {
+	Size        string `bson:"size" json:"size"`
+	Filename    string `bson:"filename" json:"filename"`
+	URL         string `bson:"url" json:"url"`
+	Width       int    `bson:"width" json:"width"`
+	Height      int    `bson:"height" json:"height"`
+	ContentType string `bson:"content_type" json:"content_type"`
+	Bytes       int64  `bson:"bytes" json:"bytes"`
+	SHA256      string `bson:"sha256,omitempty" json:"sha256,omitempty"`
+}
+
+// Kolam is one generated kolam: how it was made and where its image is stored.
+// Grid, Style, Seed and GeneratorVersion together pin down its geometry exactly.
+// CacheKey is the content address of the stored image (see service.CacheKey);
+// records generated from the same inputs share it and the image. SHA256 is
+// the hash of the image's bytes, which it is served with as its ETag, and
+// Bytes their count.
+type Kolam struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Grid             string             `bson:"grid" json:"grid"`
+	Style            string             `bson:"style" json:"style"`
+	Seed             int64              `bson:"seed" json:"seed"`
+	Generator        string             `bson:"generator" json:"generator"`
+	GeneratorVersion string             `bson:"generator_version,omitempty" json:"generator_version,omitempty"`
+	PublicID         string             `bson:"public_id" json:"public_id"`
+	Filename         string             `bson:"filename" json:"filename"`
+	URL              string             `bson:"url" json:"url"`
+	Width            int                `bson:"width,omitempty" json:"width,omitempty"`
+	Height           int                `bson:"height,omitempty" json:"height,omitempty"`
+	Bytes            int64              `bson:"bytes,omitempty" json:"bytes,omitempty"`
+	SHA256           string             `bson:"sha256,omitempty" json:"sha256,omitempty"`
+	Derivatives      []Derivative       `bson:"derivatives,omitempty" json:"derivatives,omitempty"`
+	Owner            string             `bson:"owner,omitempty" json:"owner,omitempty"`
+	Tags             []string           `bson:"tags,omitempty" json:"tags,omitempty"`
+	CacheKey         string             `bson:"cache_key,omitempty" json:"-"`
+	CreatedAt        time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// User is an account that owns uploads and generated kolams.
+type User struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Email        string             `bson:"email" json:"email"`
+	Name         string             `bson:"name,omitempty" json:"name,omitempty"`
+	PasswordHash string             `bson:"password_hash" json:"-"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Job statuses.
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// Job is a background kolam generation and its progress. When it is done,
+// KolamID names the resulting Kolam record.
+type Job struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Status     string             `bson:"status" json:"status"`
+	Stage      string             `bson:"stage,omitempty" json:"stage,omitempty"`
+	Progress   float64            `bson:"progress" json:"progress"`
+	Grid       string             `bson:"grid" json:"grid"`
+	Style      string             `bson:"style" json:"style"`
+	Generator  string             `bson:"generator" json:"generator"`
+	Seed       int64              `bson:"seed" json:"seed"`
+	Tags       []string           `bson:"tags,omitempty" json:"tags,omitempty"`
+	Owner      string             `bson:"owner,omitempty" json:"owner,omitempty"`
+	KolamID    string             `bson:"kolam_id,omitempty" json:"kolam_id,omitempty"`
+	Error      string             `bson:"error,omitempty" json:"error,omitempty"`
+	Warning    string             `bson:"warning,omitempty" json:"warning,omitempty"`
+	Attempts   int                `bson:"attempts" json:"attempts"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+	StartedAt  *time.Time         `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	FinishedAt *time.Time         `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// AllowList is the set of hosts the proxy may fetch from. Entries are exact
+// host names, or suffixes written ".example.com" or "*.example.com" that match
+// every subdomain of example.com (but not example.com itself).
+type AllowList struct {
+	exact    map[string]bool
+	suffixes []string
+}
+
+// ParseAllowList parses a comma-separated list of allow-list entries.
+func ParseAllowList(spec string) (AllowList, error) {
+	a := AllowList{exact: make(map[string]bool)}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if strings.ContainsAny(entry, "/:@ ") {
+			return AllowList{}, fmt.Errorf("proxy allow-list: invalid host %q", entry)
+		}
+		if rest, ok := strings.CutPrefix(entry, "*."); ok {
+			entry = "." + rest
+		}
+		if strings.HasPrefix(entry, ".") {
+			if strings.Count(entry, ".") < 2 {
+				// ".com" would allow a whole top-level domain
+				return AllowList{}, fmt.Errorf("proxy allow-list: suffix %q is too broad", entry)
+			}
+			a.suffixes = append(a.suffixes, entry)
+		} else {
+			a.exact[entry] = true
+		}
+	}
+	if len(a.exact) == 0 && len(a.suffixes) == 0 {
+		return AllowList{}, fmt.Errorf("proxy allow-list is empty")
+	}
+	return a, nil
+}
+
+// Check reports why u may not be fetched, or nil if it may. Only https URLs
+// on the default port, without credentials, whose host is on the list pass.
+func (a AllowList) Check(u *url.URL) error {
+	if u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", ErrNotAllowed, u.Scheme)
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: credentials in url", ErrNotAllowed)
+	}
+	if p := u.Port(); p != "" && p != "443" {
+		return fmt.Errorf("%w: port %s", ErrNotAllowed, p)
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if a.exact[host] {
+		return nil
+	}
+	for _, s := range a.suffixes {
+		if strings.HasSuffix(host, s) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: host %q", ErrNotAllowed, host)
+}
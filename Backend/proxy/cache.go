@@ -0,0 +1,275 @@
+package proxy
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry describes one cached upstream response.
+type Entry struct {
+	URL          string    `json:"url"`
+	ContentType  string    `json:"content_type"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Size         int64     `json:"size"`
+	Fetched      time.Time `json:"fetched"`
+
+	key string
+}
+
+// ModTime is the Last-Modified time of the entry, or when it was fetched if
+// upstream did not say.
+func (e *Entry) ModTime() time.Time {
+	if t, err := http.ParseTime(e.LastModified); err == nil {
+		return t
+	}
+	return e.Fetched
+}
+
+// DiskCache keeps proxied images on disk, evicting the least recently used
+// once their total size exceeds a limit. Each entry is a body file and a JSON
+// sidecar named after the hash of its URL; the index of entries lives in
+// memory and is rebuilt from the sidecars on start.
+type DiskCache struct {
+	dir      string
+	maxBytes int64
+	// TTL is how long an entry is served before it must be revalidated.
+	TTL time.Duration
+
+	mu      sync.Mutex
+	size    int64
+	order   *list.List // of *Entry, most recently used first
+	entries map[string]*list.Element
+}
+
+// OpenDiskCache opens or creates the cache in dir, holding up to maxBytes.
+func OpenDiskCache(dir string, maxBytes int64, ttl time.Duration) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create proxy cache dir: %w", err)
+	}
+	c := &DiskCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		TTL:      ttl,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load rebuilds the index from the sidecars, oldest fetch least recently
+// used, and clears out anything left half-written.
+func (c *DiskCache) load() error {
+	names, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("read proxy cache dir: %w", err)
+	}
+	var found []*Entry
+	for _, n := range names {
+		name := n.Name()
+		if strings.HasPrefix(name, "tmp-") {
+			os.Remove(filepath.Join(c.dir, name))
+			continue
+		}
+		key, ok := strings.CutSuffix(name, ".json")
+		if !ok {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(c.dir, name))
+		var e Entry
+		if err == nil {
+			err = json.Unmarshal(b, &e)
+		}
+		if err == nil {
+			_, err = os.Stat(c.bodyPath(key))
+		}
+		if err != nil {
+			c.remove(key)
+			continue
+		}
+		e.key = key
+		found = append(found, &e)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Fetched.After(found[j].Fetched) })
+	for _, e := range found {
+		c.entries[e.key] = c.order.PushBack(e)
+		c.size += e.Size
+	}
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+	log.Printf("Proxy cache: %d images, %d bytes in %s", c.order.Len(), c.size, c.dir)
+	return nil
+}
+
+func cacheKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *DiskCache) bodyPath(key string) string { return filepath.Join(c.dir, key) }
+func (c *DiskCache) metaPath(key string) string { return filepath.Join(c.dir, key+".json") }
+
+// Open returns the entry for url and its open body, or ok false on a miss.
+// The caller must close the file. Stale entries are returned too; check Fresh.
+func (c *DiskCache) Open(url string) (e *Entry, f *os.File, ok bool) {
+	key := cacheKey(url)
+	c.mu.Lock()
+	el, ok := c.entries[key]
+	if ok {
+		c.order.MoveToFront(el)
+		e = el.Value.(*Entry)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, nil, false
+	}
+	f, err := os.Open(c.bodyPath(key))
+	if err != nil {
+		log.Printf("proxy cache: open %s: %v", key, err)
+		c.drop(key)
+		return nil, nil, false
+	}
+	copied := *e
+	return &copied, f, true
+}
+
+// Fresh reports whether e may be served without asking upstream.
+func (c *DiskCache) Fresh(e *Entry) bool {
+	return time.Since(e.Fetched) < c.TTL
+}
+
+// Store writes body, at most limit bytes of it, as the entry for e.URL and
+// returns the stored body, open and rewound. The file stays readable even if the
+// entry is evicted before the caller is done with it. Exceeding limit fails
+// with ErrTooLarge and caches nothing.
+func (c *DiskCache) Store(e Entry, body io.Reader, limit int64) (*Entry, *os.File, error) {
+	e.key = cacheKey(e.URL)
+	tmp, err := os.CreateTemp(c.dir, "tmp-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("create proxy cache file: %w", err)
+	}
+	n, err := io.Copy(tmp, io.LimitReader(body, limit+1))
+	if err == nil && n > limit {
+		err = ErrTooLarge
+	}
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.bodyPath(e.key))
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, nil, err
+	}
+	e.Size = n
+	if err := c.writeMeta(&e); err != nil {
+		tmp.Close()
+		c.drop(e.key)
+		return nil, nil, err
+	}
+
+	c.mu.Lock()
+	if el, ok := c.entries[e.key]; ok {
+		c.size -= el.Value.(*Entry).Size
+		c.order.Remove(el)
+	}
+	stored := e
+	c.entries[e.key] = c.order.PushFront(&stored)
+	c.size += e.Size
+	c.evict()
+	c.mu.Unlock()
+	return &e, tmp, nil
+}
+
+// Refresh marks e as fetched now, after upstream confirmed it is unchanged.
+func (c *DiskCache) Refresh(e *Entry) {
+	key := cacheKey(e.URL)
+	c.mu.Lock()
+	el, ok := c.entries[key]
+	if ok {
+		el.Value.(*Entry).Fetched = time.Now().UTC()
+		refreshed := *el.Value.(*Entry)
+		e = &refreshed
+	}
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	if err := c.writeMeta(e); err != nil {
+		log.Printf("proxy cache: %v", err)
+	}
+}
+
+func (c *DiskCache) writeMeta(e *Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encode proxy cache entry: %w", err)
+	}
+	tmp, err := os.CreateTemp(c.dir, "tmp-")
+	if err != nil {
+		return fmt.Errorf("create proxy cache file: %w", err)
+	}
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.metaPath(cacheKey(e.URL)))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write proxy cache entry: %w", err)
+	}
+	return nil
+}
+
+// evict removes least recently used entries until the cache fits. c.mu must be held.
+func (c *DiskCache) evict() {
+	for c.size > c.maxBytes && c.order.Len() > 0 {
+		oldest := c.order.Back()
+		e := oldest.Value.(*Entry)
+		c.order.Remove(oldest)
+		delete(c.entries, e.key)
+		c.size -= e.Size
+		c.remove(e.key)
+	}
+}
+
+// drop forgets key and deletes its files.
+func (c *DiskCache) drop(key string) {
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.size -= el.Value.(*Entry).Size
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+	c.remove(key)
+}
+
+func (c *DiskCache) remove(key string) {
+	for _, p := range []string{c.metaPath(key), c.bodyPath(key)} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("proxy cache: %v", err)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+// Package proxy fetches images from an allow-list of remote hosts on behalf
+// of clients that cannot load them directly (the AR view on device). It
+// refuses to reach private addresses, bounds what it passes on, and keeps an
+// on-disk cache of what it fetched.
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrBadURL is returned for a missing or malformed URL.
+	ErrBadURL = errors.New("invalid url")
+	// ErrNotAllowed is returned for URLs, including redirect targets, outside the allow-list.
+	ErrNotAllowed = errors.New("url not allowed")
+	// ErrBlockedAddress is returned when an allowed host resolves to a private address.
+	ErrBlockedAddress = errors.New("address not allowed")
+	// ErrTooLarge is returned when the upstream body exceeds MaxBytes.
+	ErrTooLarge = errors.New("image too large")
+	// ErrNotImage is returned when upstream answers with something other than an image.
+	ErrNotImage = errors.New("upstream response is not an image")
+)
+
+// StatusError is returned when upstream answers with a status the proxy does not pass on.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("upstream returned status %d", e.Code)
+}
+
+// maxRedirects bounds the redirects followed for one fetch.
+const maxRedirects = 5
+
+// forwardedHeaders are passed upstream when the response is not cached, so
+// conditional and partial requests keep working end to end.
+var forwardedHeaders = []string{"If-None-Match", "If-Modified-Since", "Range", "If-Range"}
+
+// passedHeaders are copied from an upstream response that is passed through.
+var passedHeaders = []string{"Content-Length", "Content-Range", "Accept-Ranges", "ETag", "Last-Modified"}
+
+// Proxy fetches allowed images. Cache may be nil to fetch every request.
+type Proxy struct {
+	Allow    AllowList
+	MaxBytes int64
+	Cache    *DiskCache
+
+	client *http.Client
+
+	mu       sync.Mutex
+	inflight map[string]*fetch
+}
+
+// fetch is a cache fill in progress; later requests for the same URL wait
+// for it instead of downloading the image again.
+type fetch struct {
+	done chan struct{}
+	err  error
+}
+
+// New returns a proxy for allow. Connections to private addresses are
+// refused unless allowPrivate is set, which is meant for tests only.
+func New(allow AllowList, maxBytes int64, cache *DiskCache, allowPrivate bool) *Proxy {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = blockPrivate
+	}
+	p := &Proxy{Allow: allow, MaxBytes: maxBytes, Cache: cache, inflight: make(map[string]*fetch)}
+	p.client = &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			// never through an environment proxy, which would dial on our behalf
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConnsPerHost:   8,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 15 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return p.Allow.Check(req.URL)
+		},
+	}
+	return p
+}
+
+// Check parses raw and checks it against the allow-list.
+func (p *Proxy) Check(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, fmt.Errorf("%w: missing", ErrBadURL)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return nil, ErrBadURL
+	}
+	u.Fragment, u.RawFragment = "", ""
+	if err := p.Allow.Check(u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Serve answers r with the image at u. It returns an error only if it has
+// not written a response; failures after that abort the connection so the
+// client never mistakes a truncated image for a whole one. The X-Cache
+// header reports HIT, REVALIDATED, MISS or BYPASS.
+func (p *Proxy) Serve(w http.ResponseWriter, r *http.Request, u *url.URL) error {
+	if p.Cache == nil {
+		return p.passThrough(w, r, u)
+	}
+	key := u.String()
+	for {
+		e, f, ok := p.Cache.Open(key)
+		if ok && p.Cache.Fresh(e) {
+			defer f.Close()
+			serveEntry(w, r, e, f, "HIT")
+			return nil
+		}
+		if ok {
+			f.Close()
+		}
+		// one request fills the cache, the rest wait and look again
+		p.mu.Lock()
+		if fl, busy := p.inflight[key]; busy {
+			p.mu.Unlock()
+			select {
+			case <-fl.done:
+			case <-r.Context().Done():
+				return r.Context().Err()
+			}
+			if fl.err != nil {
+				return fl.err
+			}
+			continue
+		}
+		fl := &fetch{done: make(chan struct{})}
+		p.inflight[key] = fl
+		p.mu.Unlock()
+
+		fl.err = p.fill(w, r, u, e)
+		p.mu.Lock()
+		delete(p.inflight, key)
+		p.mu.Unlock()
+		close(fl.done)
+		return fl.err
+	}
+}
+
+// fill fetches u into the cache and serves it, revalidating stale instead
+// of downloading it again when it is set.
+func (p *Proxy) fill(w http.ResponseWriter, r *http.Request, u *url.URL, stale *Entry) error {
+	header := http.Header{}
+	if stale != nil {
+		if stale.ETag != "" {
+			header.Set("If-None-Match", stale.ETag)
+		}
+		if stale.LastModified != "" {
+			header.Set("If-Modified-Since", stale.LastModified)
+		}
+	}
+	// detached from the client, so a hang-up does not waste a download
+	// others may be waiting for
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), p.client.Timeout)
+	defer cancel()
+	resp, err := p.get(ctx, http.MethodGet, u, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && stale != nil {
+		p.Cache.Refresh(stale)
+		e, f, ok := p.Cache.Open(u.String())
+		if !ok {
+			return fmt.Errorf("proxy cache entry vanished during revalidation")
+		}
+		defer f.Close()
+		serveEntry(w, r, e, f, "REVALIDATED")
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{Code: resp.StatusCode}
+	}
+	if err := p.checkResponse(resp); err != nil {
+		return err
+	}
+	e, f, err := p.Cache.Store(Entry{
+		URL:          u.String(),
+		ContentType:  resp.Header.Get("Content-Type"),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Fetched:      time.Now().UTC(),
+	}, resp.Body, p.MaxBytes)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	serveEntry(w, r, e, f, "MISS")
+	return nil
+}
+
+// passThrough streams upstream's answer to r, forwarding conditional and range headers.
+func (p *Proxy) passThrough(w http.ResponseWriter, r *http.Request, u *url.URL) error {
+	header := http.Header{}
+	for _, h := range forwardedHeaders {
+		if v := r.Header.Get(h); v != "" {
+			header.Set(h, v)
+		}
+	}
+	method := http.MethodGet
+	if r.Method == http.MethodHead {
+		method = http.MethodHead
+	}
+	resp, err := p.get(r.Context(), method, u, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+		if err := p.checkResponse(resp); err != nil {
+			return err
+		}
+		w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	case http.StatusNotModified, http.StatusRequestedRangeNotSatisfiable:
+	default:
+		return &StatusError{Code: resp.StatusCode}
+	}
+	for _, h := range passedHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	setHeaders(w, "BYPASS")
+	w.WriteHeader(resp.StatusCode)
+	if method == http.MethodHead {
+		return nil
+	}
+	n, err := io.Copy(w, io.LimitReader(resp.Body, p.MaxBytes+1))
+	if err != nil || n > p.MaxBytes {
+		panic(http.ErrAbortHandler)
+	}
+	return nil
+}
+
+func (p *Proxy) get(ctx context.Context, method string, u *url.URL, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header = header
+	req.Header.Set("User-Agent", "kolam-backend-proxy")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", u.Redacted(), err)
+	}
+	return resp, nil
+}
+
+// checkResponse rejects bodies that are not images or are declared too large.
+func (p *Proxy) checkResponse(resp *http.Response) error {
+	mt, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mt, "image/") {
+		return fmt.Errorf("%w: %q", ErrNotImage, resp.Header.Get("Content-Type"))
+	}
+	if resp.ContentLength > p.MaxBytes {
+		return fmt.Errorf("%w: %d bytes", ErrTooLarge, resp.ContentLength)
+	}
+	return nil
+}
+
+// serveEntry answers r from a cache entry. http.ServeContent takes care of
+// If-None-Match, If-Modified-Since and Range against the cached copy.
+func serveEntry(w http.ResponseWriter, r *http.Request, e *Entry, f *os.File, cache string) {
+	w.Header().Set("Content-Type", e.ContentType)
+	if e.ETag != "" {
+		w.Header().Set("ETag", e.ETag)
+	}
+	setHeaders(w, cache)
+	w.Header().Set("Age", strconv.Itoa(int(time.Since(e.Fetched).Seconds())))
+	http.ServeContent(w, r, "", e.ModTime(), f)
+}
+
+// setHeaders sets the headers common to every proxied image. The response
+// comes from our origin, so it must never be sniffed or run as a document.
+func setHeaders(w http.ResponseWriter, cache string) {
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	w.Header().Set("X-Cache", cache)
+}
@@ -0,0 +1,173 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/ansh0014/KolamApp/kolam"
+)
+
+// MaxPixels bounds the longer side of a rasterized image. Rasterizing holds
+// the image (4 bytes a pixel) and a coverage mask (another 4) at once, so a
+// render at the cap needs about 128 MiB, and each concurrent generation,
+// request or job worker, may need that much.
+const MaxPixels = 4096
+
+// Size selects the raster resolution. Pixels, when set, is the length of the
+// longer side. Otherwise DPI is used with one grid unit per inch, which matches
+// the ML service (one inch per cell at 300 dpi). DPI is lowered if the image
+// would exceed MaxPixels.
+type Size struct {
+	Pixels int `json:"pixels,omitempty"`
+	DPI    int `json:"dpi,omitempty"`
+}
+
+// PNG rasterizes p and writes it as a PNG image.
+func PNG(w io.Writer, p *kolam.Pattern, opts Options, size Size) error {
+	img, err := Rasterize(p, opts, size)
+	if err != nil {
+		return err
+	}
+	return Encode(w, img)
+}
+
+// Encode writes an image made by Rasterize as a PNG.
+func Encode(w io.Writer, img image.Image) error {
+	// kolam images are mostly flat colour; faster compression costs little size
+	enc := png.Encoder{CompressionLevel: png.BestSpeed}
+	return enc.Encode(w, img)
+}
+
+// Rasterize draws p into a new image: antialiased strokes with round joins and
+// caps, then filled dots on top.
+func Rasterize(p *kolam.Pattern, opts Options, size Size) (*image.NRGBA, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	minX, minY, maxX, maxY := Bounds(p, opts)
+	extent := math.Max(maxX-minX, maxY-minY)
+
+	var scale float64
+	switch {
+	case size.Pixels > 0:
+		if size.Pixels > MaxPixels {
+			return nil, fmt.Errorf("image size must be at most %d pixels", MaxPixels)
+		}
+		scale = float64(size.Pixels) / extent
+	case size.DPI > 0:
+		scale = math.Min(float64(size.DPI), MaxPixels/extent)
+	default:
+		return nil, fmt.Errorf("image size or dpi is required")
+	}
+	width := max(1, int(math.Ceil((maxX-minX)*scale)))
+	height := max(1, int(math.Ceil((maxY-minY)*scale)))
+
+	c := &canvas{w: width, h: height, pix: make([]float32, width*height)}
+	toPx := func(pt kolam.Point) (float64, float64) {
+		return (pt.X - minX) * scale, (pt.Y - minY) * scale
+	}
+
+	bg := [4]float64{}
+	if opts.Background != "none" {
+		bg, _ = parseColor(opts.Background)
+	}
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < width*height; i++ {
+		setPixel(out.Pix[i*4:i*4+4], bg)
+	}
+
+	hw := opts.StrokeWidth * scale / 2
+	for _, s := range p.Strokes {
+		for i := 1; i < len(s); i++ {
+			ax, ay := toPx(s[i-1])
+			bx, by := toPx(s[i])
+			c.capsule(ax, ay, bx, by, hw)
+		}
+		if len(s) == 1 {
+			ax, ay := toPx(s[0])
+			c.capsule(ax, ay, ax, ay, hw)
+		}
+	}
+	stroke, _ := parseColor(opts.StrokeColor)
+	c.composite(out, stroke)
+
+	if opts.DotRadius > 0 {
+		c.clear()
+		r := opts.DotRadius * scale
+		for _, d := range p.Dots {
+			x, y := toPx(d)
+			c.capsule(x, y, x, y, r)
+		}
+		dot, _ := parseColor(opts.DotColor)
+		c.composite(out, dot)
+	}
+	return out, nil
+}
+
+// canvas is a coverage mask. Shapes are merged with max() so overlapping
+// strokes of one colour do not darken where they cross.
+type canvas struct {
+	w, h int
+	pix  []float32
+}
+
+func (c *canvas) clear() {
+	clear(c.pix)
+}
+
+// capsule covers every pixel within r of the segment a-b, antialiased over one pixel.
+func (c *canvas) capsule(ax, ay, bx, by, r float64) {
+	x0 := max(0, int(math.Floor(math.Min(ax, bx)-r-1)))
+	y0 := max(0, int(math.Floor(math.Min(ay, by)-r-1)))
+	x1 := min(c.w-1, int(math.Ceil(math.Max(ax, bx)+r+1)))
+	y1 := min(c.h-1, int(math.Ceil(math.Max(ay, by)+r+1)))
+
+	dx, dy := bx-ax, by-ay
+	lenSq := dx*dx + dy*dy
+	for y := y0; y <= y1; y++ {
+		py := float64(y) + 0.5
+		for x := x0; x <= x1; x++ {
+			px := float64(x) + 0.5
+			t := 0.0
+			if lenSq > 0 {
+				t = math.Max(0, math.Min(1, ((px-ax)*dx+(py-ay)*dy)/lenSq))
+			}
+			ex, ey := px-(ax+t*dx), py-(ay+t*dy)
+			cov := float32(math.Max(0, math.Min(1, r+0.5-math.Sqrt(ex*ex+ey*ey))))
+			if i := y*c.w + x; cov > c.pix[i] {
+				c.pix[i] = cov
+			}
+		}
+	}
+}
+
+// composite paints col through the coverage mask onto img.
+func (c *canvas) composite(img *image.NRGBA, col [4]float64) {
+	for i, cov := range c.pix {
+		if cov == 0 {
+			continue
+		}
+		px := img.Pix[i*4 : i*4+4]
+		sa := col[3] * float64(cov)
+		da := float64(px[3]) / 255
+		oa := sa + da*(1-sa)
+		if oa == 0 {
+			continue
+		}
+		var o [4]float64
+		for k := 0; k < 3; k++ {
+			o[k] = (col[k]*sa + float64(px[k])/255*da*(1-sa)) / oa
+		}
+		o[3] = oa
+		setPixel(px, o)
+	}
+}
+
+func setPixel(px []uint8, c [4]float64) {
+	for k := 0; k < 4; k++ {
+		px[k] = uint8(math.Round(math.Max(0, math.Min(1, c[k])) * 255))
+	}
+}
@@ -0,0 +1,128 @@
+// Package render draws kolam geometry as SVG or PNG.
+package render
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ansh0014/KolamApp/kolam"
+)
+
+// Options controls the look of a rendered kolam. Lengths are in grid units,
+// where adjacent dots of a square grid are 1 apart.
+type Options struct {
+	StrokeWidth float64 `json:"stroke_width"`
+	StrokeColor string  `json:"stroke"`
+	DotRadius   float64 `json:"dot_radius"`
+	DotColor    string  `json:"dot"`
+	// Background is a colour or "none" for transparent.
+	Background string `json:"background"`
+	// Padding is added around the geometry bounds on every side.
+	Padding float64 `json:"padding"`
+}
+
+// DefaultOptions matches the look of the ML service PNGs: thick black strokes and small black dots.
+func DefaultOptions() Options {
+	return Options{
+		StrokeWidth: 0.08,
+		StrokeColor: "#000000",
+		DotRadius:   0.04,
+		DotColor:    "#000000",
+		Background:  "#ffffff",
+		Padding:     0.25,
+	}
+}
+
+// namedColors are the colour names accepted besides hex values.
+var namedColors = map[string]string{
+	"black":   "#000000",
+	"white":   "#ffffff",
+	"red":     "#ff0000",
+	"green":   "#008000",
+	"blue":    "#0000ff",
+	"yellow":  "#ffff00",
+	"orange":  "#ffa500",
+	"purple":  "#800080",
+	"magenta": "#ff00ff",
+	"pink":    "#ffc0cb",
+	"maroon":  "#800000",
+	"navy":    "#000080",
+	"gray":    "#808080",
+	"grey":    "#808080",
+	"gold":    "#ffd700",
+	"brown":   "#a52a2a",
+}
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+
+// parseColor converts a hex or named colour to RGBA components in [0, 1].
+func parseColor(s string) ([4]float64, error) {
+	if named, ok := namedColors[strings.ToLower(s)]; ok {
+		s = named
+	}
+	if !hexColor.MatchString(s) {
+		return [4]float64{}, fmt.Errorf("unknown colour %q", s)
+	}
+	h := s[1:]
+	if len(h) == 3 {
+		h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]})
+	}
+	if len(h) == 6 {
+		h += "ff"
+	}
+	var c [4]float64
+	for i := range c {
+		v, _ := strconv.ParseUint(h[i*2:i*2+2], 16, 8)
+		c[i] = float64(v) / 255
+	}
+	return c, nil
+}
+
+// Validate checks that every length is in range and every colour is a hex or named colour.
+func (o Options) Validate() error {
+	if o.StrokeWidth <= 0 || o.StrokeWidth > 1 {
+		return fmt.Errorf("stroke_width must be in (0, 1]")
+	}
+	if o.DotRadius < 0 || o.DotRadius > 0.5 {
+		return fmt.Errorf("dot_radius must be in [0, 0.5]")
+	}
+	if o.Padding < 0 || o.Padding > 10 {
+		return fmt.Errorf("padding must be in [0, 10]")
+	}
+	for name, c := range map[string]string{"stroke": o.StrokeColor, "dot": o.DotColor, "background": o.Background} {
+		if name == "background" && c == "none" {
+			continue
+		}
+		if _, err := parseColor(c); err != nil {
+			return fmt.Errorf("%s must be a hex colour like #d81b60 or a colour name", name)
+		}
+	}
+	return nil
+}
+
+// Bounds returns the bounding box of the dots and strokes, grown by the stroke
+// half-width or dot radius and by opts.Padding.
+func Bounds(p *kolam.Pattern, opts Options) (minX, minY, maxX, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	grow := func(pt kolam.Point) {
+		minX, maxX = math.Min(minX, pt.X), math.Max(maxX, pt.X)
+		minY, maxY = math.Min(minY, pt.Y), math.Max(maxY, pt.Y)
+	}
+	for _, d := range p.Dots {
+		grow(d)
+	}
+	for _, s := range p.Strokes {
+		for _, pt := range s {
+			grow(pt)
+		}
+	}
+	if math.IsInf(minX, 1) {
+		return 0, 0, 1, 1
+	}
+	m := math.Max(opts.StrokeWidth/2, opts.DotRadius) + opts.Padding
+	return minX - m, minY - m, maxX + m, maxY + m
+}
@@ -0,0 +1,75 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/ansh0014/KolamApp/kolam"
+)
+
+// SVG writes p as a standalone SVG document whose viewBox is in grid units.
+func SVG(w io.Writer, p *kolam.Pattern, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	minX, minY, maxX, maxY := Bounds(p, opts)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="%s %s %s %s">`,
+		num(minX), num(minY), num(maxX-minX), num(maxY-minY))
+	bw.WriteString("\n")
+	if opts.Background != "none" {
+		fmt.Fprintf(bw, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`,
+			num(minX), num(minY), num(maxX-minX), num(maxY-minY), opts.Background)
+		bw.WriteString("\n")
+	}
+
+	fmt.Fprintf(bw, `<g fill="none" stroke="%s" stroke-width="%s" stroke-linecap="round" stroke-linejoin="round">`,
+		opts.StrokeColor, num(opts.StrokeWidth))
+	bw.WriteString("\n")
+	for _, s := range p.Strokes {
+		if len(s) < 2 {
+			continue
+		}
+		bw.WriteString(`<polyline points="`)
+		for i, pt := range s {
+			if i > 0 {
+				bw.WriteByte(' ')
+			}
+			bw.WriteString(num(pt.X))
+			bw.WriteByte(',')
+			bw.WriteString(num(pt.Y))
+		}
+		bw.WriteString("\"/>\n")
+	}
+	bw.WriteString("</g>\n")
+
+	if opts.DotRadius > 0 {
+		fmt.Fprintf(bw, `<g fill="%s">`, opts.DotColor)
+		bw.WriteString("\n")
+		for _, d := range p.Dots {
+			fmt.Fprintf(bw, `<circle cx="%s" cy="%s" r="%s"/>`, num(d.X), num(d.Y), num(opts.DotRadius))
+			bw.WriteString("\n")
+		}
+		bw.WriteString("</g>\n")
+	}
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+// num formats v with four decimals, trimming trailing zeros, to keep documents small.
+func num(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 4, 64)
+	for len(s) > 1 && s[len(s)-1] == '0' {
+		s = s[:len(s)-1]
+	}
+	if s[len(s)-1] == '.' {
+		s = s[:len(s)-1]
+	}
+	if s == "-0" {
+		s = "0"
+	}
+	return s
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidCursor is returned by ParseCursor for malformed cursors.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks a position in a listing ordered by (created_at, id).
+type Cursor struct {
+	CreatedAt time.Time
+	ID        primitive.ObjectID
+}
+
+// CursorAt returns the cursor positioned at a record.
+func CursorAt(createdAt time.Time, id primitive.ObjectID) *Cursor {
+	return &Cursor{CreatedAt: createdAt, ID: id}
+}
+
+// String encodes the cursor as an opaque URL-safe token.
+func (c Cursor) String() string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixNano(), 36) + "." + c.ID.Hex()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseCursor decodes a token produced by Cursor.String.
+func ParseCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	ts, hex, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	ns, err := strconv.ParseInt(ts, 36, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{CreatedAt: time.Unix(0, ns).UTC(), ID: id}, nil
+}
+
+// passed reports whether a record at (createdAt, id) comes after the cursor
+// in the given sort order.
+func (c *Cursor) passed(createdAt time.Time, id primitive.ObjectID, oldest bool) bool {
+	if !createdAt.Equal(c.CreatedAt) {
+		return createdAt.After(c.CreatedAt) == oldest
+	}
+	if oldest {
+		return id.Hex() > c.ID.Hex()
+	}
+	return id.Hex() < c.ID.Hex()
+}
@@ -0,0 +1,257 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ansh0014/KolamApp/model"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryImageRepository keeps images in memory. It is meant for tests and
+// for running the backend without MongoDB.
+type MemoryImageRepository struct {
+	t memTable[model.Image]
+}
+
+// NewMemoryImageRepository returns an empty repository.
+func NewMemoryImageRepository() *MemoryImageRepository {
+	return &MemoryImageRepository{t: newMemTable(func(img *model.Image) (primitive.ObjectID, time.Time) {
+		return img.ID, img.CreatedAt
+	})}
+}
+
+func (m *MemoryImageRepository) Create(ctx context.Context, img *model.Image) error {
+	if img.ID.IsZero() {
+		img.ID = primitive.NewObjectID()
+	}
+	img.CreatedAt = now()
+	m.t.put(img.ID, *img)
+	return nil
+}
+
+func (m *MemoryImageRepository) Get(ctx context.Context, id string) (*model.Image, error) {
+	return m.t.get(id)
+}
+
+func (m *MemoryImageRepository) List(ctx context.Context, f ImageFilter) ([]model.Image, error) {
+	return m.t.list(f.match, f.Page), nil
+}
+
+func (m *MemoryImageRepository) Update(ctx context.Context, img *model.Image) error {
+	return m.t.replace(img.ID, *img)
+}
+
+func (m *MemoryImageRepository) Delete(ctx context.Context, id string) error {
+	return m.t.delete(id)
+}
+
+// MemoryKolamRepository keeps kolams in memory.
+type MemoryKolamRepository struct {
+	t memTable[model.Kolam]
+}
+
+// NewMemoryKolamRepository returns an empty repository.
+func NewMemoryKolamRepository() *MemoryKolamRepository {
+	return &MemoryKolamRepository{t: newMemTable(func(k *model.Kolam) (primitive.ObjectID, time.Time) {
+		return k.ID, k.CreatedAt
+	})}
+}
+
+func (m *MemoryKolamRepository) Create(ctx context.Context, k *model.Kolam) error {
+	if k.ID.IsZero() {
+		k.ID = primitive.NewObjectID()
+	}
+	k.CreatedAt = now()
+	m.t.put(k.ID, *k)
+	return nil
+}
+
+func (m *MemoryKolamRepository) Get(ctx context.Context, id string) (*model.Kolam, error) {
+	return m.t.get(id)
+}
+
+func (m *MemoryKolamRepository) List(ctx context.Context, f KolamFilter) ([]model.Kolam, error) {
+	return m.t.list(f.match, f.Page), nil
+}
+
+func (m *MemoryKolamRepository) Update(ctx context.Context, k *model.Kolam) error {
+	return m.t.replace(k.ID, *k)
+}
+
+func (m *MemoryKolamRepository) Delete(ctx context.Context, id string) error {
+	return m.t.delete(id)
+}
+
+// MemoryUserRepository keeps users in memory.
+type MemoryUserRepository struct {
+	t memTable[model.User]
+}
+
+// NewMemoryUserRepository returns an empty repository.
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{t: newMemTable(func(u *model.User) (primitive.ObjectID, time.Time) {
+		return u.ID, u.CreatedAt
+	})}
+}
+
+func (m *MemoryUserRepository) Create(ctx context.Context, u *model.User) error {
+	if u.ID.IsZero() {
+		u.ID = primitive.NewObjectID()
+	}
+	u.CreatedAt = now()
+	if !m.t.putUnique(u.ID, *u, func(o *model.User) bool { return o.Email == u.Email }) {
+		return ErrDuplicate
+	}
+	return nil
+}
+
+func (m *MemoryUserRepository) Get(ctx context.Context, id string) (*model.User, error) {
+	return m.t.get(id)
+}
+
+func (m *MemoryUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	users := m.t.list(func(u *model.User) bool { return u.Email == email }, Page{Limit: 1})
+	if len(users) == 0 {
+		return nil, ErrNotFound
+	}
+	return &users[0], nil
+}
+
+// MemoryJobRepository keeps jobs in memory.
+type MemoryJobRepository struct {
+	t memTable[model.Job]
+}
+
+// NewMemoryJobRepository returns an empty repository.
+func NewMemoryJobRepository() *MemoryJobRepository {
+	return &MemoryJobRepository{t: newMemTable(func(j *model.Job) (primitive.ObjectID, time.Time) {
+		return j.ID, j.CreatedAt
+	})}
+}
+
+func (m *MemoryJobRepository) Create(ctx context.Context, j *model.Job) error {
+	if j.ID.IsZero() {
+		j.ID = primitive.NewObjectID()
+	}
+	j.CreatedAt = now()
+	j.UpdatedAt = j.CreatedAt
+	m.t.put(j.ID, *j)
+	return nil
+}
+
+func (m *MemoryJobRepository) Get(ctx context.Context, id string) (*model.Job, error) {
+	return m.t.get(id)
+}
+
+func (m *MemoryJobRepository) List(ctx context.Context, f JobFilter) ([]model.Job, error) {
+	return m.t.list(f.match, f.Page), nil
+}
+
+func (m *MemoryJobRepository) Update(ctx context.Context, j *model.Job) error {
+	j.UpdatedAt = now()
+	return m.t.replace(j.ID, *j)
+}
+
+// memTable is a mutex-guarded map of records keyed by ObjectID.
+type memTable[T any] struct {
+	mu   sync.RWMutex
+	rows map[primitive.ObjectID]T
+	key  func(*T) (primitive.ObjectID, time.Time)
+}
+
+func newMemTable[T any](key func(*T) (primitive.ObjectID, time.Time)) memTable[T] {
+	return memTable[T]{rows: make(map[primitive.ObjectID]T), key: key}
+}
+
+func (t *memTable[T]) put(id primitive.ObjectID, v T) {
+	t.mu.Lock()
+	t.rows[id] = v
+	t.mu.Unlock()
+}
+
+// putUnique stores v unless an existing row conflicts with it.
+func (t *memTable[T]) putUnique(id primitive.ObjectID, v T, conflicts func(*T) bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, row := range t.rows {
+		if conflicts(&row) {
+			return false
+		}
+	}
+	t.rows[id] = v
+	return true
+}
+
+func (t *memTable[T]) get(id string) (*T, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+	t.mu.RLock()
+	v, ok := t.rows[oid]
+	t.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &v, nil
+}
+
+func (t *memTable[T]) replace(id primitive.ObjectID, v T) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.rows[id]; !ok {
+		return ErrNotFound
+	}
+	t.rows[id] = v
+	return nil
+}
+
+func (t *memTable[T]) delete(id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidID
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.rows[oid]; !ok {
+		return ErrNotFound
+	}
+	delete(t.rows, oid)
+	return nil
+}
+
+// list returns one page of matching rows, ordered like the Mongo implementations.
+func (t *memTable[T]) list(match func(*T) bool, p Page) []T {
+	oldest := p.oldest()
+	t.mu.RLock()
+	var out []T
+	for _, v := range t.rows {
+		if !match(&v) {
+			continue
+		}
+		if p.After != nil {
+			if id, at := t.key(&v); !p.After.passed(at, id, oldest) {
+				continue
+			}
+		}
+		out = append(out, v)
+	}
+	t.mu.RUnlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		idI, atI := t.key(&out[i])
+		idJ, atJ := t.key(&out[j])
+		if !atI.Equal(atJ) {
+			return atI.After(atJ) != oldest
+		}
+		return (idI.Hex() > idJ.Hex()) != oldest
+	})
+	if p.Limit > 0 && len(out) > p.Limit {
+		out = out[:p.Limit]
+	}
+	return out
+}
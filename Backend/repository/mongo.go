@@ -0,0 +1,294 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ansh0014/KolamApp/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoImageRepository stores images in a MongoDB collection.
+type MongoImageRepository struct {
+	coll *mongo.Collection
+}
+
+// NewMongoImageRepository wraps the given collection (normally "images").
+func NewMongoImageRepository(coll *mongo.Collection) *MongoImageRepository {
+	return &MongoImageRepository{coll: coll}
+}
+
+func (m *MongoImageRepository) Create(ctx context.Context, img *model.Image) error {
+	if img.ID.IsZero() {
+		img.ID = primitive.NewObjectID()
+	}
+	img.CreatedAt = now()
+	if _, err := m.coll.InsertOne(ctx, img); err != nil {
+		return fmt.Errorf("insert image: %w", err)
+	}
+	return nil
+}
+
+func (m *MongoImageRepository) Get(ctx context.Context, id string) (*model.Image, error) {
+	return findByID[model.Image](ctx, m.coll, id, "image")
+}
+
+func (m *MongoImageRepository) List(ctx context.Context, f ImageFilter) ([]model.Image, error) {
+	q := bson.M{}
+	for field, v := range map[string]string{"owner": f.Owner, "tags": f.Tag} {
+		if v != "" {
+			q[field] = v
+		}
+	}
+	addFile(q, f.File)
+	addCreatedRange(q, f.CreatedAfter, f.CreatedBefore)
+	return findAll[model.Image](ctx, m.coll, q, f.Page, "images")
+}
+
+func (m *MongoImageRepository) Update(ctx context.Context, img *model.Image) error {
+	return replaceByID(ctx, m.coll, img.ID, img, "image")
+}
+
+func (m *MongoImageRepository) Delete(ctx context.Context, id string) error {
+	return deleteByID(ctx, m.coll, id, "image")
+}
+
+// MongoKolamRepository stores kolams in a MongoDB collection.
+type MongoKolamRepository struct {
+	coll *mongo.Collection
+}
+
+// NewMongoKolamRepository wraps the given collection (normally "kolams").
+func NewMongoKolamRepository(coll *mongo.Collection) *MongoKolamRepository {
+	return &MongoKolamRepository{coll: coll}
+}
+
+func (m *MongoKolamRepository) Create(ctx context.Context, k *model.Kolam) error {
+	if k.ID.IsZero() {
+		k.ID = primitive.NewObjectID()
+	}
+	k.CreatedAt = now()
+	if _, err := m.coll.InsertOne(ctx, k); err != nil {
+		return fmt.Errorf("insert kolam: %w", err)
+	}
+	return nil
+}
+
+func (m *MongoKolamRepository) Get(ctx context.Context, id string) (*model.Kolam, error) {
+	return findByID[model.Kolam](ctx, m.coll, id, "kolam")
+}
+
+func (m *MongoKolamRepository) List(ctx context.Context, f KolamFilter) ([]model.Kolam, error) {
+	q := bson.M{}
+	for field, v := range map[string]string{"grid": f.Grid, "style": f.Style, "generator": f.Generator, "owner": f.Owner, "tags": f.Tag, "cache_key": f.CacheKey} {
+		if v != "" {
+			q[field] = v
+		}
+	}
+	addFile(q, f.File)
+	addCreatedRange(q, f.CreatedAfter, f.CreatedBefore)
+	return findAll[model.Kolam](ctx, m.coll, q, f.Page, "kolams")
+}
+
+func (m *MongoKolamRepository) Update(ctx context.Context, k *model.Kolam) error {
+	return replaceByID(ctx, m.coll, k.ID, k, "kolam")
+}
+
+func (m *MongoKolamRepository) Delete(ctx context.Context, id string) error {
+	return deleteByID(ctx, m.coll, id, "kolam")
+}
+
+// MongoUserRepository stores users in a MongoDB collection with a unique
+// index on email (created by config.InitMongo).
+type MongoUserRepository struct {
+	coll *mongo.Collection
+}
+
+// NewMongoUserRepository wraps the given collection (normally "users").
+func NewMongoUserRepository(coll *mongo.Collection) *MongoUserRepository {
+	return &MongoUserRepository{coll: coll}
+}
+
+func (m *MongoUserRepository) Create(ctx context.Context, u *model.User) error {
+	if u.ID.IsZero() {
+		u.ID = primitive.NewObjectID()
+	}
+	u.CreatedAt = now()
+	_, err := m.coll.InsertOne(ctx, u)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return fmt.Errorf("insert user: %w", err)
+	}
+	return nil
+}
+
+func (m *MongoUserRepository) Get(ctx context.Context, id string) (*model.User, error) {
+	return findByID[model.User](ctx, m.coll, id, "user")
+}
+
+func (m *MongoUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	var u model.User
+	err := m.coll.FindOne(ctx, bson.M{"email": email}).Decode(&u)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find user: %w", err)
+	}
+	return &u, nil
+}
+
+// MongoJobRepository stores jobs in a MongoDB collection.
+type MongoJobRepository struct {
+	coll *mongo.Collection
+}
+
+// NewMongoJobRepository wraps the given collection (normally "jobs").
+func NewMongoJobRepository(coll *mongo.Collection) *MongoJobRepository {
+	return &MongoJobRepository{coll: coll}
+}
+
+func (m *MongoJobRepository) Create(ctx context.Context, j *model.Job) error {
+	if j.ID.IsZero() {
+		j.ID = primitive.NewObjectID()
+	}
+	j.CreatedAt = now()
+	j.UpdatedAt = j.CreatedAt
+	if _, err := m.coll.InsertOne(ctx, j); err != nil {
+		return fmt.Errorf("insert job: %w", err)
+	}
+	return nil
+}
+
+func (m *MongoJobRepository) Get(ctx context.Context, id string) (*model.Job, error) {
+	return findByID[model.Job](ctx, m.coll, id, "job")
+}
+
+func (m *MongoJobRepository) List(ctx context.Context, f JobFilter) ([]model.Job, error) {
+	q := bson.M{}
+	if len(f.Statuses) > 0 {
+		q["status"] = bson.M{"$in": f.Statuses}
+	}
+	if f.Owner != "" {
+		q["owner"] = f.Owner
+	}
+	return findAll[model.Job](ctx, m.coll, q, f.Page, "jobs")
+}
+
+func (m *MongoJobRepository) Update(ctx context.Context, j *model.Job) error {
+	j.UpdatedAt = now()
+	return replaceByID(ctx, m.coll, j.ID, j, "job")
+}
+
+// now is the creation timestamp for new records, truncated to the millisecond
+// precision Mongo stores so cursors round-trip exactly.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// addFile narrows q to records whose image or a derivative is stored under key.
+func addFile(q bson.M, key string) {
+	if key != "" {
+		q["$or"] = bson.A{bson.M{"filename": key}, bson.M{"derivatives.filename": key}}
+	}
+}
+
+func addCreatedRange(q bson.M, after, before time.Time) {
+	created := bson.M{}
+	if !after.IsZero() {
+		created["$gt"] = after
+	}
+	if !before.IsZero() {
+		created["$lt"] = before
+	}
+	if len(created) > 0 {
+		q["created_at"] = created
+	}
+}
+
+func findByID[T any](ctx context.Context, coll *mongo.Collection, id, what string) (*T, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+	var doc T
+	err = coll.FindOne(ctx, bson.M{"_id": oid}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find %s: %w", what, err)
+	}
+	return &doc, nil
+}
+
+// findAll returns one page of documents matching q, ordered by (created_at, _id).
+func findAll[T any](ctx context.Context, coll *mongo.Collection, q bson.M, p Page, what string) ([]T, error) {
+	dir, cmp := -1, "$lt"
+	if p.oldest() {
+		dir, cmp = 1, "$gt"
+	}
+	q = afterCursor(q, p.After, cmp)
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: dir}, {Key: "_id", Value: dir}})
+	if p.Limit > 0 {
+		opts.SetLimit(int64(p.Limit))
+	}
+	cur, err := coll.Find(ctx, q, opts)
+	if err != nil {
+		return nil, fmt.Errorf("find %s: %w", what, err)
+	}
+	var out []T
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", what, err)
+	}
+	return out, nil
+}
+
+// afterCursor narrows q to the documents past c in the cmp direction. The
+// cursor's own $or is kept apart from any in q, such as the one addFile sets.
+func afterCursor(q bson.M, c *Cursor, cmp string) bson.M {
+	if c == nil {
+		return q
+	}
+	page := bson.M{"$or": bson.A{
+		bson.M{"created_at": bson.M{cmp: c.CreatedAt}},
+		bson.M{"created_at": c.CreatedAt, "_id": bson.M{cmp: c.ID}},
+	}}
+	if len(q) == 0 {
+		return page
+	}
+	return bson.M{"$and": []bson.M{q, page}}
+}
+
+func replaceByID(ctx context.Context, coll *mongo.Collection, oid primitive.ObjectID, doc interface{}, what string) error {
+	res, err := coll.ReplaceOne(ctx, bson.M{"_id": oid}, doc)
+	if err != nil {
+		return fmt.Errorf("update %s: %w", what, err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func deleteByID(ctx context.Context, coll *mongo.Collection, id, what string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidID
+	}
+	res, err := coll.DeleteOne(ctx, bson.M{"_id": oid})
+	if err != nil {
+		return fmt.Errorf("delete %s: %w", what, err)
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestAfterCursorKeepsFileFilter(t *testing.T) {
+	c := &Cursor{CreatedAt: time.Unix(1700000000, 0), ID: primitive.NewObjectID()}
+	q := bson.M{"owner": "u1"}
+	addFile(q, "a.png")
+
+	got := afterCursor(q, c, "$lt")
+	and, ok := got["$and"].([]bson.M)
+	if !ok || len(and) != 2 {
+		t.Fatalf("query = %v, want an $and of the filter and the cursor", got)
+	}
+	if !reflect.DeepEqual(and[0]["$or"], bson.A{bson.M{"filename": "a.png"}, bson.M{"derivatives.filename": "a.png"}}) {
+		t.Errorf("file condition = %v", and[0]["$or"])
+	}
+	if and[0]["owner"] != "u1" {
+		t.Errorf("owner condition = %v", and[0]["owner"])
+	}
+	page, _ := and[1]["$or"].(bson.A)
+	if len(page) != 2 {
+		t.Errorf("cursor condition = %v", and[1])
+	}
+
+	if got := afterCursor(bson.M{}, c, "$lt"); got["$or"] == nil {
+		t.Errorf("cursor on an empty filter = %v", got)
+	}
+	if got := afterCursor(q, nil, "$lt"); !reflect.DeepEqual(got, q) {
+		t.Errorf("no cursor changed the filter to %v", got)
+	}
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/ansh0014/KolamApp/model"
+)
+
+// ErrNotFound is returned when no record matches the requested ID.
+var ErrNotFound = errors.New("record not found")
+
+// ErrInvalidID is returned when an ID is not a valid hex ObjectID.
+var ErrInvalidID = errors.New("invalid id")
+
+// ErrDuplicate is returned when a record violates a uniqueness constraint.
+var ErrDuplicate = errors.New("duplicate record")
+
+// Sort orders for List calls. Records are ordered by creation time, then ID.
+const (
+	SortNewest = "newest"
+	SortOldest = "oldest"
+)
+
+// Page selects one page of a List call.
+type Page struct {
+	Limit int
+	// Sort is SortNewest (the default) or SortOldest.
+	Sort string
+	// After continues a listing from the last record of a previous page.
+	After *Cursor
+}
+
+func (p Page) oldest() bool { return p.Sort == SortOldest }
+
+// ImageFilter narrows an ImageRepository.List call. Zero values are ignored.
+type ImageFilter struct {
+	// File matches images stored under this key or with a derivative stored under it.
+	File          string
+	Owner         string
+	Tag           string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Page
+}
+
+// ImageRepository stores image metadata.
+type ImageRepository interface {
+	// Create inserts img, filling in ID and CreatedAt.
+	Create(ctx context.Context, img *model.Image) error
+	Get(ctx context.Context, id string) (*model.Image, error)
+	// List returns one page of images matching f.
+	List(ctx context.Context, f ImageFilter) ([]model.Image, error)
+	// Update replaces the stored record with img (matched by img.ID).
+	Update(ctx context.Context, img *model.Image) error
+	Delete(ctx context.Context, id string) error
+}
+
+// KolamFilter narrows a KolamRepository.List call. Zero values are ignored.
+type KolamFilter struct {
+	// File matches kolams whose image, or a derivative of it, is stored under this key.
+	File          string
+	Grid          string
+	Style         string
+	Generator     string
+	Owner         string
+	Tag           string
+	CacheKey      string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Page
+}
+
+// KolamRepository stores generated kolam records.
+type KolamRepository interface {
+	// Create inserts k, filling in ID and CreatedAt.
+	Create(ctx context.Context, k *model.Kolam) error
+	Get(ctx context.Context, id string) (*model.Kolam, error)
+	// List returns one page of kolams matching f.
+	List(ctx context.Context, f KolamFilter) ([]model.Kolam, error)
+	// Update replaces the stored record with k (matched by k.ID).
+	Update(ctx context.Context, k *model.Kolam) error
+	Delete(ctx context.Context, id string) error
+}
+
+// UserRepository stores user accounts. Emails are unique.
+type UserRepository interface {
+	// Create inserts u, filling in ID and CreatedAt. It returns ErrDuplicate
+	// if the email is already registered.
+	Create(ctx context.Context, u *model.User) error
+	Get(ctx context.Context, id string) (*model.User, error)
+	GetByEmail(ctx context.Context, email string) (*model.User, error)
+}
+
+// JobFilter narrows a JobRepository.List call. Zero values are ignored.
+type JobFilter struct {
+	// Statuses matches jobs in any of the given statuses.
+	Statuses []string
+	Owner    string
+	Page
+}
+
+// JobRepository stores background jobs.
+type JobRepository interface {
+	// Create inserts j, filling in ID, CreatedAt and UpdatedAt.
+	Create(ctx context.Context, j *model.Job) error
+	Get(ctx context.Context, id string) (*model.Job, error)
+	// List returns one page of jobs matching f.
+	List(ctx context.Context, f JobFilter) ([]model.Job, error)
+	// Update replaces the stored record with j (matched by j.ID), setting UpdatedAt.
+	Update(ctx context.Context, j *model.Job) error
+}
+
+func (f JobFilter) match(j *model.Job) bool {
+	switch {
+	case len(f.Statuses) > 0 && !slices.Contains(f.Statuses, j.Status),
+		f.Owner != "" && j.Owner != f.Owner:
+		return false
+	}
+	return true
+}
+
+func (f KolamFilter) match(k *model.Kolam) bool {
+	switch {
+	case f.Grid != "" && k.Grid != f.Grid,
+		f.Style != "" && k.Style != f.Style,
+		f.Generator != "" && k.Generator != f.Generator,
+		f.Owner != "" && k.Owner != f.Owner,
+		f.Tag != "" && !slices.Contains(k.Tags, f.Tag),
+		f.CacheKey != "" && k.CacheKey != f.CacheKey,
+		f.File != "" && !storedAs(f.File, k.Filename, k.Derivatives),
+		!f.CreatedAfter.IsZero() && !k.CreatedAt.After(f.CreatedAfter),
+		!f.CreatedBefore.IsZero() && !k.CreatedAt.Before(f.CreatedBefore):
+		return false
+	}
+	return true
+}
+
+func (f ImageFilter) match(img *model.Image) bool {
+	switch {
+	case f.File != "" && !storedAs(f.File, img.Filename, img.Derivatives),
+		f.Owner != "" && img.Owner != f.Owner,
+		f.Tag != "" && !slices.Contains(img.Tags, f.Tag),
+		!f.CreatedAfter.IsZero() && !img.CreatedAt.After(f.CreatedAfter),
+		!f.CreatedBefore.IsZero() && !img.CreatedAt.Before(f.CreatedBefore):
+		return false
+	}
+	return true
+}
+
+// storedAs reports whether key names the image filename or one of its derivatives.
+func storedAs(key, filename string, derivatives []model.Derivative) bool {
+	return key == filename || slices.ContainsFunc(derivatives, func(d model.Derivative) bool { return d.Filename == key })
+}
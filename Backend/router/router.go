@@ -2,16 +2,119 @@ package router
 
 import (
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/ansh0014/KolamApp/auth"
 	"github.com/ansh0014/KolamApp/handler"
 )
 
-func New() http.Handler {
+// APIPrefix versions every route. Each route is also served without it so
+// app builds from before the prefix keep working.
+const APIPrefix = "/v1"
+
+// Server timeouts. Handlers that legitimately take longer, such as chunk
+// uploads and event streams, extend their own deadlines.
+const (
+	ReadTimeout  = 15 * time.Second
+	WriteTimeout = 15 * time.Second
+	IdleTimeout  = 60 * time.Second
+)
+
+// NewServer returns the HTTP server for h on addr, with the timeouts above.
+func NewServer(addr string, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         addr,
+		Handler:      h,
+		ReadTimeout:  ReadTimeout,
+		WriteTimeout: WriteTimeout,
+		IdleTimeout:  IdleTimeout,
+	}
+}
+
+func New(s *handler.Server) http.Handler {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/", handler.HealthHandler)
-	mux.HandleFunc("/images/", handler.ImageServeHandler)
-	mux.HandleFunc("/upload", handler.ImageUploadHandler)
-	mux.HandleFunc("/generate-kolam", handler.GenerateKolamHandler)
-	mux.HandleFunc("/proxy", handler.ProxyImageHandler)
-	return mux
+	handle := func(pattern string, h http.HandlerFunc) {
+		method, path, _ := strings.Cut(pattern, " ")
+		mux.HandleFunc(method+" "+APIPrefix+path, h)
+		mux.HandleFunc(pattern, h)
+	}
+	required := auth.Required(handler.AuthError)
+
+	handle("GET /{$}", handler.HealthHandler)
+	handle("GET /healthz", handler.HealthHandler)
+	handle("GET /readyz", s.ReadyHandler)
+	handle("GET /ml-health", s.MLServiceHealthCheckHandler)
+
+	handle("POST /auth/signup", s.SignupHandler)
+	handle("POST /auth/login", s.LoginHandler)
+	handle("POST /auth/refresh", s.RefreshHandler)
+	handle("GET /auth/me", required(s.MeHandler))
+
+	handle("GET /images", s.ImageListHandler)
+	handle("GET /images/{name}", s.ImageServeHandler)
+	handle("POST /upload", required(s.ImageUploadHandler))
+	handle("GET /proxy", s.ProxyImageHandler)
+	handle("POST /uploads", required(s.CreateUploadHandler))
+	handle("GET /uploads/{id}", required(s.UploadStatusHandler))
+	handle("PATCH /uploads/{id}", required(s.UploadChunkHandler))
+	handle("PUT /uploads/{id}", required(s.UploadChunkHandler))
+	handle("DELETE /uploads/{id}", required(s.DeleteUploadHandler))
+	handle("POST /uploads/{id}/complete", required(s.CompleteUploadHandler))
+
+	handle("POST /generate-kolam", required(s.GenerateKolamHandler))
+	handle("GET /kolams", s.KolamListHandler)
+	handle("GET /kolams/{file}", s.KolamsHandler)
+	handle("POST /kolams/{file}", s.KolamsHandler)
+	handle("POST /api/generate", s.KolamGeometryHandler)
+	handle("POST /classify", s.ClassifyHandler)
+
+	handle("POST /jobs/generate", required(s.SubmitGenerateJobHandler))
+	handle("GET /jobs/{id}", required(s.JobHandler))
+	handle("GET /jobs/{id}/events", required(s.JobEventsHandler))
+
+	return handler.RequestID(auth.Middleware(s.Tokens, s.Users, handler.AuthError)(withRouteErrors(mux)))
 }
+
+// withRouteErrors replaces the mux's plain-text 404 and 405 responses with
+// JSON ones and answers OPTIONS for any routed path.
+func withRouteErrors(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, pattern := mux.Handler(r)
+		if pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		// No route matched. The mux's fallback handler sets Allow when the
+		// path exists under other methods, which tells 405 from 404.
+		probe := &headerRecorder{header: http.Header{}}
+		h.ServeHTTP(probe, r)
+		allow := probe.header.Get("Allow")
+		switch {
+		case allow == "":
+			handler.NotFound(w, r)
+		case r.Method == http.MethodOptions:
+			options(w, allow)
+		default:
+			handler.MethodNotAllowed(w, r, allow)
+		}
+	})
+}
+
+// options answers OPTIONS, including CORS preflight requests, with the
+// methods the path accepts.
+func options(w http.ResponseWriter, allow string) {
+	allow += ", OPTIONS"
+	w.Header().Set("Allow", allow)
+	w.Header().Set("Access-Control-Allow-Methods", allow)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// headerRecorder captures headers and discards everything else.
+type headerRecorder struct {
+	header http.Header
+}
+
+func (h *headerRecorder) Header() http.Header         { return h.header }
+func (h *headerRecorder) Write(b []byte) (int, error) { return len(b), nil }
+func (h *headerRecorder) WriteHeader(int)             {}
@@ -0,0 +1,181 @@
+package router_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ansh0014/KolamApp/router"
+)
+
+func TestRoutes(t *testing.T) {
+	s, token := newTestServer(t)
+	h := router.New(s)
+
+	tests := []struct {
+		name         string
+		method, path string
+		auth         bool
+		status       int
+		// code is the error code of an error response
+		code  string
+		allow string
+	}{
+		{name: "root", method: "GET", path: "/", status: 200},
+		{name: "versioned route", method: "GET", path: "/v1/healthz", status: 200},
+		{name: "legacy route", method: "GET", path: "/healthz", status: 200},
+		{name: "root is not a catch-all", method: "GET", path: "/no-such-route", status: 404, code: "not_found"},
+		{name: "unknown nested path", method: "GET", path: "/v1/healthz/extra", status: 404, code: "not_found"},
+		{name: "versioned root", method: "GET", path: "/v1/", status: 200},
+		{name: "versioned root is not a catch-all", method: "GET", path: "/v1/no-such-route", status: 404, code: "not_found"},
+		{name: "wrong method", method: "DELETE", path: "/v1/healthz", status: 405, code: "method_not_allowed", allow: "GET, HEAD"},
+		{name: "wrong method on legacy path", method: "GET", path: "/upload", status: 405, code: "method_not_allowed", allow: "POST"},
+		{name: "options", method: "OPTIONS", path: "/v1/images", status: 204, allow: "GET, HEAD, OPTIONS"},
+		{name: "authentication required", method: "GET", path: "/v1/auth/me", status: 401, code: "unauthorized"},
+		{name: "authenticated", method: "GET", path: "/v1/auth/me", auth: true, status: 200},
+		{name: "invalid parameter", method: "GET", path: "/v1/images/x.png?size=huge", status: 400, code: "invalid_parameter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.auth {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.allow != "" && rec.Header().Get("Allow") != tt.allow {
+				t.Errorf("Allow = %q, want %q", rec.Header().Get("Allow"), tt.allow)
+			}
+			if rec.Header().Get("X-Request-ID") == "" {
+				t.Error("no X-Request-ID header")
+			}
+			if tt.code == "" {
+				return
+			}
+			checkError(t, rec, tt.code)
+		})
+	}
+}
+
+// checkError checks that rec holds a JSON error with the given code, in the
+// shape every error response shares.
+func checkError(t *testing.T, rec *httptest.ResponseRecorder, code string) map[string]interface{} {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type = %q, want JSON", ct)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("error body is not JSON: %v: %s", err, rec.Body)
+	}
+	if body["code"] != code {
+		t.Errorf("code = %v, want %s", body["code"], code)
+	}
+	if msg, _ := body["message"].(string); msg == "" {
+		t.Error("error has no message")
+	}
+	if id := rec.Header().Get("X-Request-ID"); body["request_id"] != id {
+		t.Errorf("request_id = %v, want the X-Request-ID header %q", body["request_id"], id)
+	}
+	return body
+}
+
+func TestErrorDetails(t *testing.T) {
+	s, _ := newTestServer(t)
+	h := router.New(s)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/images/x.png?size=huge", nil))
+	body := checkError(t, rec, "invalid_parameter")
+	if d, _ := body["details"].(map[string]interface{}); d["field"] != "size" {
+		t.Errorf("details = %v, want field size", body["details"])
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("PUT", "/v1/healthz", nil))
+	body = checkError(t, rec, "method_not_allowed")
+	if d, _ := body["details"].(map[string]interface{}); d["allow"] != rec.Header().Get("Allow") {
+		t.Errorf("details = %v, want the allowed methods", body["details"])
+	}
+}
+
+func TestGenerateRejectsBadStyle(t *testing.T) {
+	s, token := newTestServer(t)
+	h := router.New(s)
+
+	for _, tt := range []struct{ generator, style string }{
+		{"native", "spiral"},
+		{"ml", "../../etc/passwd"},
+		{"ml", "a/b"},
+		{"ml", strings.Repeat("x", 100)},
+	} {
+		for _, path := range []string{"/v1/generate-kolam", "/v1/jobs/generate"} {
+			body := `{"grid_size":"5x5","generator":"` + tt.generator + `","style":"` + tt.style + `"}`
+			req := httptest.NewRequest("POST", path, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != 400 {
+				t.Errorf("%s %s style %q: status = %d, want 400", path, tt.generator, tt.style, rec.Code)
+				continue
+			}
+			resp := checkError(t, rec, "invalid_parameter")
+			if d, _ := resp["details"].(map[string]interface{}); d["field"] != "style" {
+				t.Errorf("details = %v, want field style", resp["details"])
+			}
+		}
+	}
+}
+
+func TestLoginFailuresLookAlike(t *testing.T) {
+	s, _ := newTestServer(t)
+	h := router.New(s)
+
+	signup := httptest.NewRequest("POST", "/v1/auth/signup", strings.NewReader(`{"email":"known@example.com","password":"correct horse"}`))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, signup)
+	if rec.Code != 201 {
+		t.Fatalf("signup: status %d; body %s", rec.Code, rec.Body)
+	}
+
+	var messages []interface{}
+	for _, body := range []string{
+		`{"email":"known@example.com","password":"wrong password"}`,
+		`{"email":"unknown@example.com","password":"wrong password"}`,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/auth/login", strings.NewReader(body)))
+		if rec.Code != 401 {
+			t.Fatalf("%s: status = %d, want 401", body, rec.Code)
+		}
+		messages = append(messages, checkError(t, rec, "invalid_credentials")["message"])
+	}
+	if messages[0] != messages[1] {
+		t.Errorf("messages differ: %q and %q", messages[0], messages[1])
+	}
+}
+
+func TestRequestIDIsEchoed(t *testing.T) {
+	s, _ := newTestServer(t)
+	h := router.New(s)
+
+	for _, tt := range []struct{ sent, want string }{
+		{"abc-123", "abc-123"},
+		{"bad id with spaces", ""},
+	} {
+		req := httptest.NewRequest("GET", "/no-such-route", nil)
+		req.Header.Set("X-Request-ID", tt.sent)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		got := rec.Header().Get("X-Request-ID")
+		if tt.want != "" && got != tt.want || tt.want == "" && (got == "" || got == tt.sent) {
+			t.Errorf("sent %q, got X-Request-ID %q", tt.sent, got)
+		}
+		checkError(t, rec, "not_found")
+	}
+}
@@ -0,0 +1,145 @@
+package router_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ansh0014/KolamApp/auth"
+	"github.com/ansh0014/KolamApp/handler"
+	"github.com/ansh0014/KolamApp/model"
+	"github.com/ansh0014/KolamApp/repository"
+	"github.com/ansh0014/KolamApp/router"
+	"github.com/ansh0014/KolamApp/storage"
+	"github.com/ansh0014/KolamApp/upload"
+)
+
+// newTestServer returns a server over in-memory stores and the bearer token
+// of a user in it.
+func newTestServer(t *testing.T) (*handler.Server, string) {
+	t.Helper()
+	sessions, err := upload.OpenSessions(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &handler.Server{
+		Store:    storage.NewMemory("/images/"),
+		Images:   repository.NewMemoryImageRepository(),
+		Kolams:   repository.NewMemoryKolamRepository(),
+		Users:    repository.NewMemoryUserRepository(),
+		Tokens:   &auth.Issuer{Secret: []byte("test secret"), AccessTTL: time.Hour, RefreshTTL: time.Hour},
+		Uploads:  upload.Limits{MaxBytes: 1 << 20, MaxDimension: 1024, MaxPixels: 1 << 20},
+		Sessions: sessions,
+	}
+	u := &model.User{Email: "test@example.com"}
+	if err := s.Users.Create(context.Background(), u); err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := s.Tokens.Issue(u.ID.Hex(), auth.Access)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, token
+}
+
+// TestSlowChunkOutlastsServerTimeouts sends a chunk that takes longer than
+// the server's write timeout to arrive and checks that it is still answered.
+func TestSlowChunkOutlastsServerTimeouts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits out the server write timeout")
+	}
+	s, token := newTestServer(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := router.NewServer("", router.New(s))
+	go srv.Serve(ln)
+	defer srv.Close()
+	base := "http://" + ln.Addr().String()
+
+	var file bytes.Buffer
+	if err := png.Encode(&file, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodPost, base+"/v1/uploads", strings.NewReader(`{"size":`+strconv.Itoa(file.Len())+`}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sess struct {
+		UploadURL string `json:"upload_url"`
+	}
+	json.NewDecoder(resp.Body).Decode(&sess)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create upload: status %d", resp.StatusCode)
+	}
+
+	// the first bytes now, the rest after the write timeout has passed
+	body, pw := io.Pipe()
+	go func() {
+		data := file.Bytes()
+		pw.Write(data[:8])
+		time.Sleep(router.WriteTimeout + 2*time.Second)
+		pw.Write(data[8:])
+		pw.Close()
+	}()
+	req, _ = http.NewRequest(http.MethodPatch, base+sess.UploadURL, body)
+	req.ContentLength = int64(file.Len())
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", "0")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("slow chunk got no response: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("slow chunk: status %d", resp.StatusCode)
+	}
+	if got, want := resp.Header.Get("Upload-Offset"), strconv.Itoa(file.Len()); got != want {
+		t.Errorf("Upload-Offset = %s, want %s", got, want)
+	}
+}
+
+// TestUploadIgnoresClaimedContentType sends HTML labelled as a PNG.
+func TestUploadIgnoresClaimedContentType(t *testing.T) {
+	s, token := newTestServer(t)
+	h := router.New(s)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition": {`form-data; name="file"; filename="cat.png"`},
+		"Content-Type":        {"image/png"},
+	})
+	part.Write([]byte("<!DOCTYPE html><html><script>alert(1)</script></html>"))
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("status = %d, want 415; body %s", rec.Code, rec.Body)
+	}
+	checkError(t, rec, "unsupported_media_type")
+	if objs, _ := s.Store.List(context.Background(), ""); len(objs) != 0 {
+		t.Errorf("rejected upload was stored: %v", objs)
+	}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/ansh0014/KolamApp/model"
+	"github.com/ansh0014/KolamApp/repository"
+)
+
+// CacheKey is the content address of a generated image: the hash of every
+// input that affects its bytes. render describes the rasterisation settings
+// and is empty when the generator renders its own image.
+func CacheKey(req GenerateRequest, version, render string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%d\x00%s", req.Generator, version, req.Grid.Spec, req.Style, req.Seed, render)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Cache finds images that were already generated from the same inputs. It
+// keeps the most recently used entries in memory and falls back to the
+// cache_key of the kolam records in the metadata store.
+type Cache struct {
+	kolams repository.KolamRepository
+
+	mu      sync.Mutex
+	size    int
+	order   *list.List // of *cacheEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key   string
+	kolam model.Kolam
+}
+
+// NewCache returns a cache over kolams holding up to size entries in memory.
+func NewCache(kolams repository.KolamRepository, size int) *Cache {
+	return &Cache{
+		kolams:  kolams,
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns a kolam record whose image was generated under key, or nil.
+// Store errors are logged and treated as a miss.
+func (c *Cache) Get(ctx context.Context, key string) *model.Kolam {
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		k := el.Value.(*cacheEntry).kolam
+		c.mu.Unlock()
+		return &k
+	}
+	c.mu.Unlock()
+
+	found, err := c.kolams.List(ctx, repository.KolamFilter{
+		CacheKey: key,
+		Page:     repository.Page{Limit: 1, Sort: repository.SortOldest},
+	})
+	if err != nil {
+		log.Printf("generation cache lookup: %v", err)
+		return nil
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	c.Add(&found[0])
+	return &found[0]
+}
+
+// Add remembers k under its CacheKey, evicting the least recently used entry
+// when the memory tier is full.
+func (c *Cache) Add(k *model.Kolam) {
+	if k.CacheKey == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[k.CacheKey]; ok {
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[k.CacheKey] = c.order.PushFront(&cacheEntry{key: k.CacheKey, kolam: *k})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
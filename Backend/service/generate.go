@@ -0,0 +1,299 @@
+// Package service holds the application workflows shared by the synchronous
+// HTTP handlers and the background job workers.
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/ansh0014/KolamApp/derivative"
+	"github.com/ansh0014/KolamApp/grid"
+	"github.com/ansh0014/KolamApp/kolam"
+	"github.com/ansh0014/KolamApp/ml"
+	"github.com/ansh0014/KolamApp/model"
+	"github.com/ansh0014/KolamApp/render"
+	"github.com/ansh0014/KolamApp/repository"
+	"github.com/ansh0014/KolamApp/storage"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Generation stages reported to a Progress callback, in order.
+const (
+	StageGenerating = "generating"
+	StageRendering  = "rendering"
+	StageUploading  = "uploading"
+	StageSaving     = "saving"
+)
+
+var (
+	// ErrUnknownGenerator is returned for generators other than "ml" and "native".
+	ErrUnknownGenerator = errors.New("unknown generator")
+	// ErrSaveRecord wraps a failure to save the kolam record after the image
+	// was stored; the returned Result is still usable.
+	ErrSaveRecord = errors.New("save kolam record")
+	// ErrMLService wraps failures of the ML service.
+	ErrMLService = errors.New("ml service")
+	// ErrStorage wraps failures to store the generated image.
+	ErrStorage = errors.New("storage upload")
+)
+
+// PublicMessage describes a Generate error in terms fit to show a client,
+// without the internal detail the error itself may carry.
+func PublicMessage(err error) string {
+	switch {
+	case errors.Is(err, ml.ErrCircuitOpen):
+		return "ml service unavailable, try again later"
+	case errors.Is(err, ErrMLService):
+		return "ml service failed to generate the kolam"
+	case errors.Is(err, ErrStorage):
+		return "failed to store the generated image"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "generation timed out"
+	case errors.Is(err, kolam.ErrUnknownStyle), errors.Is(err, ErrUnknownGenerator):
+		return err.Error()
+	}
+	return "generation failed"
+}
+
+// GenerateRequest describes one kolam to generate.
+type GenerateRequest struct {
+	Grid      *grid.DotGrid
+	Style     string
+	Generator string
+	// Seed drives every random choice; see kolam.NewSeed.
+	Seed  int64
+	Owner string
+	Tags  []string
+}
+
+// Result is a finished generation. Pattern is set for native generations only.
+// Cached reports that the image of an earlier identical generation was reused.
+type Result struct {
+	Kolam   *model.Kolam
+	Pattern *kolam.Pattern
+	Cached  bool
+}
+
+// Event is a progress report from Generate. Stage events mark each step of
+// the pipeline; for native generations they are followed by one Dots event
+// and one Stroke event per stroke, in drawing order, once the geometry is ready.
+type Event struct {
+	Stage string
+	// Fraction is the overall progress in [0, 1].
+	Fraction float64
+	Dots     []kolam.Point
+	Stroke   []kolam.Point
+	// Index and Total number a Stroke event (Index counts from 0).
+	Index, Total int
+}
+
+// Progress receives the Events of one generation.
+type Progress func(Event)
+
+// Generator produces kolam images, stores them and records them.
+type Generator struct {
+	Store  storage.BlobStore
+	Kolams repository.KolamRepository
+	ML     *ml.Client
+	// Tiles, if set, replaces the native generator's built-in tiles.
+	Tiles []kolam.Tile
+	// Cache, if set, lets a request reuse the stored image of an identical
+	// earlier one instead of generating and uploading it again.
+	Cache *Cache
+}
+
+// Generate runs the whole pipeline for req. progress may be nil.
+func (g *Generator) Generate(ctx context.Context, req GenerateRequest, progress Progress) (*Result, error) {
+	if progress == nil {
+		progress = func(Event) {}
+	}
+	res := &Result{}
+
+	var (
+		imgBytes []byte
+		// raster is the decoded image when it was rendered here
+		raster  image.Image
+		version string
+		key     string
+		err     error
+	)
+	progress(Event{Stage: StageGenerating})
+	switch req.Generator {
+	case "ml":
+		// The service's version is only known once it has answered; until
+		// then every ML request misses the cache.
+		if v := g.ML.Version(); v != "" {
+			if hit := g.cached(ctx, CacheKey(req, v, "")); hit != nil {
+				return g.reuse(ctx, req, hit, res, progress)
+			}
+		}
+		img, err := g.ML.GenerateKolamPNG(ctx, req.Grid, req.Style, req.Seed)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrMLService, err)
+		}
+		imgBytes, version = img.PNG, img.Version
+		key = CacheKey(req, version, "")
+	case "native":
+		res.Pattern, err = kolam.Generate(kolam.Options{Grid: req.Grid, Style: req.Style, Seed: req.Seed, Tiles: g.Tiles})
+		if err != nil {
+			return nil, err
+		}
+		version = kolam.VersionOf(g.Tiles)
+		emitGeometry(res.Pattern, progress)
+		opts, size := render.DefaultOptions(), render.Size{DPI: 300}
+		key = CacheKey(req, version, fmt.Sprintf("png %+v %+v max %d", opts, size, render.MaxPixels))
+		if hit := g.cached(ctx, key); hit != nil {
+			return g.reuse(ctx, req, hit, res, progress)
+		}
+		progress(Event{Stage: StageRendering, Fraction: 0.4})
+		img, err := render.Rasterize(res.Pattern, opts, size)
+		if err != nil {
+			return nil, fmt.Errorf("render png: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := render.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("render png: %w", err)
+		}
+		imgBytes, raster = buf.Bytes(), img
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownGenerator, req.Generator)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	progress(Event{Stage: StageUploading, Fraction: 0.7})
+	// The record's ID names the image, so generations that finish together
+	// never share a key and overwrite one another.
+	id := primitive.NewObjectID()
+	filename := fmt.Sprintf("kolam_%s_%s_%s.png", req.Grid.Spec, strings.ReplaceAll(req.Style, " ", "_"), id.Hex())
+	obj, err := g.Store.Put(ctx, filename, bytes.NewReader(imgBytes), "image/png")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStorage, err)
+	}
+	// smaller renditions for the gallery and AR views; the kolam is usable without them
+	var derivatives []model.Derivative
+	if raster != nil {
+		derivatives, err = derivative.FromImage(ctx, g.Store, filename, raster)
+	} else {
+		derivatives, err = derivative.Make(ctx, g.Store, filename, imgBytes)
+	}
+	if err != nil {
+		log.Printf("derivatives of %s: %v", filename, err)
+	}
+
+	progress(Event{Stage: StageSaving, Fraction: 0.9})
+	k := &model.Kolam{
+		ID:               id,
+		Grid:             req.Grid.Spec,
+		Style:            req.Style,
+		Seed:             req.Seed,
+		Generator:        req.Generator,
+		GeneratorVersion: version,
+		PublicID:         strings.TrimSuffix(obj.Key, filepath.Ext(obj.Key)),
+		Filename:         filename,
+		URL:              obj.URL,
+		Bytes:            obj.Size,
+		SHA256:           obj.SHA256,
+		Owner:            req.Owner,
+		Tags:             req.Tags,
+		CacheKey:         key,
+		Derivatives:      derivatives,
+	}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(imgBytes)); err == nil {
+		k.Width, k.Height = cfg.Width, cfg.Height
+	}
+	res.Kolam = k
+	if err := g.Kolams.Create(ctx, k); err != nil {
+		return res, fmt.Errorf("%w: %w", ErrSaveRecord, err)
+	}
+	if g.Cache != nil {
+		g.Cache.Add(k)
+	}
+	return res, nil
+}
+
+// cached returns the record of an earlier generation stored under key, or nil.
+func (g *Generator) cached(ctx context.Context, key string) *model.Kolam {
+	if g.Cache == nil {
+		return nil
+	}
+	return g.Cache.Get(ctx, key)
+}
+
+// reuse records a new kolam for req that points at the image of hit.
+func (g *Generator) reuse(ctx context.Context, req GenerateRequest, hit *model.Kolam, res *Result, progress Progress) (*Result, error) {
+	progress(Event{Stage: StageSaving, Fraction: 0.9})
+	res.Cached = true
+	res.Kolam = &model.Kolam{
+		Grid:             req.Grid.Spec,
+		Style:            req.Style,
+		Seed:             req.Seed,
+		Generator:        req.Generator,
+		GeneratorVersion: hit.GeneratorVersion,
+		PublicID:         hit.PublicID,
+		Filename:         hit.Filename,
+		URL:              hit.URL,
+		Width:            hit.Width,
+		Height:           hit.Height,
+		Bytes:            hit.Bytes,
+		SHA256:           hit.SHA256,
+		Derivatives:      hit.Derivatives,
+		Owner:            req.Owner,
+		Tags:             req.Tags,
+		CacheKey:         hit.CacheKey,
+	}
+	if err := g.Kolams.Create(ctx, res.Kolam); err != nil {
+		return res, fmt.Errorf("%w: %w", ErrSaveRecord, err)
+	}
+	return res, nil
+}
+
+// emitGeometry reports the dots and then each stroke in drawing order, so a
+// client can draw the kolam before the image is rendered.
+func emitGeometry(p *kolam.Pattern, progress Progress) {
+	progress(Event{Stage: StageGenerating, Fraction: 0.2, Dots: p.Dots})
+	strokes := kolam.OrderStrokes(p.Strokes)
+	for i, st := range strokes {
+		progress(Event{
+			Stage:    StageGenerating,
+			Fraction: 0.2 + 0.2*float64(i+1)/float64(len(strokes)),
+			Stroke:   st,
+			Index:    i,
+			Total:    len(strokes),
+		})
+	}
+}
+
+// RunJob generates the kolam described by a background job and records the
+// resulting Kolam ID on it. It has the signature of a jobs.Runner.
+func (g *Generator) RunJob(ctx context.Context, job *model.Job, progress Progress) error {
+	dg, err := grid.Parse(job.Grid)
+	if err != nil {
+		return err
+	}
+	res, err := g.Generate(ctx, GenerateRequest{
+		Grid:      dg,
+		Style:     job.Style,
+		Generator: job.Generator,
+		Seed:      job.Seed,
+		Owner:     job.Owner,
+		Tags:      job.Tags,
+	}, progress)
+	if errors.Is(err, ErrSaveRecord) {
+		job.Warning = "metadata save failed; image stored at " + res.Kolam.URL
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	job.KolamID = res.Kolam.ID.Hex()
+	return nil
+}
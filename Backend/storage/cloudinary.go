@@ -0,0 +1,297 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ansh0014/KolamApp/config"
+	"github.com/cloudinary/cloudinary-go/v2"
+	"github.com/cloudinary/cloudinary-go/v2/api"
+	"github.com/cloudinary/cloudinary-go/v2/api/admin"
+	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+)
+
+// Cloudinary stores objects as Cloudinary image assets.
+// A key "name.png" maps to the public ID "<Folder>/name". Each asset's
+// SHA-256 is kept in its context metadata under sumContextKey.
+type Cloudinary struct {
+	Folder     string
+	cld        *cloudinary.Cloudinary
+	httpClient *http.Client
+}
+
+// NewCloudinary builds a Cloudinary store from the config package credentials.
+func NewCloudinary(folder string) (*Cloudinary, error) {
+	var cld *cloudinary.Cloudinary
+	var err error
+	if config.CloudinaryURL != "" {
+		cld, err = cloudinary.NewFromURL(config.CloudinaryURL)
+	} else {
+		cld, err = cloudinary.NewFromParams(config.CloudinaryCloudName, config.CloudinaryAPIKey, config.CloudinaryAPISecret)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cloudinary init: %w", err)
+	}
+	return &Cloudinary{
+		Folder:     folder,
+		cld:        cld,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+const sumContextKey = "sha256"
+
+func (c *Cloudinary) publicID(key string) string {
+	base := strings.TrimSuffix(key, path.Ext(key))
+	if c.Folder == "" {
+		return base
+	}
+	return c.Folder + "/" + base
+}
+
+// Ping calls the Admin API ping endpoint, which also checks the credentials.
+func (c *Cloudinary) Ping(ctx context.Context) error {
+	resp, err := c.cld.Admin.Ping(ctx)
+	if err != nil {
+		return fmt.Errorf("cloudinary ping: %w", err)
+	}
+	if resp.Error.Message != "" {
+		return fmt.Errorf("cloudinary ping: %s", resp.Error.Message)
+	}
+	return nil
+}
+
+// Put uploads r as an image asset. Existing assets are not overwritten.
+// r is read fully first, as its hash goes with the upload.
+func (c *Cloudinary) Put(ctx context.Context, key string, r io.Reader, contentType string) (Object, error) {
+	if !ValidKey(key) {
+		return Object{}, fmt.Errorf("invalid key %q", key)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Object{}, fmt.Errorf("read object: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	overwrite := false
+	resp, err := c.cld.Upload.Upload(ctx, bytes.NewReader(data), uploader.UploadParams{
+		PublicID:  c.publicID(key),
+		Overwrite: &overwrite,
+		Context:   api.CldAPIMap{sumContextKey: hex.EncodeToString(sum[:])},
+	})
+	if err != nil {
+		return Object{}, fmt.Errorf("cloudinary upload: %w", err)
+	}
+	if resp.Error.Message != "" {
+		return Object{}, fmt.Errorf("cloudinary upload: %s", resp.Error.Message)
+	}
+	if resp.SecureURL == "" || resp.PublicID == "" {
+		return Object{}, fmt.Errorf("cloudinary upload did not return URL/public_id")
+	}
+	return Object{
+		Key:         key,
+		Size:        int64(resp.Bytes),
+		ContentType: ContentTypeFor(key),
+		ModTime:     resp.CreatedAt.UTC(),
+		SHA256:      hex.EncodeToString(sum[:]),
+		URL:         resp.SecureURL,
+	}, nil
+}
+
+// Get looks the asset up and returns a reader over its delivery URL. Nothing
+// is downloaded until the first Read, and then only from the current offset,
+// so serving a HEAD, a 304 or a byte range fetches no more than it needs.
+func (c *Cloudinary) Get(ctx context.Context, key string) (io.ReadSeekCloser, Object, error) {
+	obj, err := c.Stat(ctx, key)
+	if err != nil {
+		return nil, Object{}, err
+	}
+	return c.open(ctx, obj), obj, nil
+}
+
+// open returns a reader over obj's delivery URL without calling the Admin API.
+func (c *Cloudinary) open(ctx context.Context, obj Object) io.ReadSeekCloser {
+	return &remoteReader{ctx: ctx, client: c.httpClient, url: obj.URL, size: obj.Size}
+}
+
+// Delete destroys the asset.
+func (c *Cloudinary) Delete(ctx context.Context, key string) error {
+	resp, err := c.cld.Upload.Destroy(ctx, uploader.DestroyParams{PublicID: c.publicID(key)})
+	if err != nil {
+		return fmt.Errorf("cloudinary destroy: %w", err)
+	}
+	if resp.Error.Message != "" {
+		return fmt.Errorf("cloudinary destroy: %s", resp.Error.Message)
+	}
+	if resp.Result == "not found" {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Stat looks up the asset through the Admin API.
+func (c *Cloudinary) Stat(ctx context.Context, key string) (Object, error) {
+	resp, err := c.cld.Admin.Asset(ctx, admin.AssetParams{PublicID: c.publicID(key)})
+	if err != nil {
+		return Object{}, fmt.Errorf("cloudinary asset: %w", err)
+	}
+	if resp.Error.Message != "" {
+		if strings.Contains(strings.ToLower(resp.Error.Message), "not found") {
+			return Object{}, ErrNotFound
+		}
+		return Object{}, fmt.Errorf("cloudinary asset: %s", resp.Error.Message)
+	}
+	return Object{
+		Key:         key,
+		Size:        int64(resp.Bytes),
+		ContentType: ContentTypeFor(key),
+		ModTime:     resp.CreatedAt.UTC(),
+		SHA256:      resp.Context.Custom[sumContextKey],
+		URL:         resp.SecureURL,
+	}, nil
+}
+
+// List pages through the Admin API for assets under Folder whose key starts with prefix.
+func (c *Cloudinary) List(ctx context.Context, prefix string) ([]Object, error) {
+	params := admin.AssetsParams{
+		AssetType:    api.Image,
+		DeliveryType: "upload",
+		Prefix:       c.publicID(prefix),
+		MaxResults:   500,
+	}
+	var out []Object
+	for {
+		resp, err := c.cld.Admin.Assets(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("cloudinary assets: %w", err)
+		}
+		if resp.Error.Message != "" {
+			return nil, fmt.Errorf("cloudinary assets: %s", resp.Error.Message)
+		}
+		for _, a := range resp.Assets {
+			key := path.Base(a.PublicID)
+			if a.Format != "" {
+				key += "." + a.Format
+			}
+			out = append(out, Object{
+				Key:         key,
+				Size:        int64(a.Bytes),
+				ContentType: ContentTypeFor(key),
+				ModTime:     a.CreatedAt.UTC(),
+				URL:         a.SecureURL,
+			})
+		}
+		if resp.NextCursor == "" {
+			return out, nil
+		}
+		params.NextCursor = resp.NextCursor
+	}
+}
+
+// URL builds the delivery URL for key without calling the API.
+func (c *Cloudinary) URL(key string) string {
+	a, err := c.cld.Image(c.publicID(key))
+	if err != nil {
+		return ""
+	}
+	u, err := a.String()
+	if err != nil {
+		return ""
+	}
+	return u + path.Ext(key)
+}
+
+// remoteReader reads size bytes from url with HTTP range requests, opening a
+// new one whenever it is moved to a different offset.
+type remoteReader struct {
+	ctx    context.Context
+	client *http.Client
+	url    string
+	size   int64
+
+	off  int64
+	body io.ReadCloser
+}
+
+func (r *remoteReader) Read(p []byte) (int, error) {
+	if r.off >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.body.Read(p)
+	r.off += int64(n)
+	if errors.Is(err, io.EOF) && r.off < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (r *remoteReader) open() error {
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	if r.off > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.off))
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch asset: %w", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// the range was ignored; skip to the offset
+		if _, err := io.CopyN(io.Discard, resp.Body, r.off); err != nil {
+			resp.Body.Close()
+			return fmt.Errorf("fetch asset: %w", err)
+		}
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return ErrNotFound
+	default:
+		resp.Body.Close()
+		return fmt.Errorf("fetch asset: status %d", resp.StatusCode)
+	}
+	r.body = resp.Body
+	return nil
+}
+
+func (r *remoteReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.off
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.New("seek to negative offset")
+	}
+	if offset != r.off && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.off = offset
+	return offset, nil
+}
+
+func (r *remoteReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
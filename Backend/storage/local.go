@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Local stores objects as files in a directory on disk. Each file's SHA-256
+// is kept in a hidden ".<key>.sha256" sidecar next to it, so it is hashed
+// once rather than on every request; files without one are hashed when first
+// read.
+type Local struct {
+	Dir     string
+	BaseURL string
+}
+
+// NewLocal returns a Local store rooted at dir. URLs are baseURL + key.
+func NewLocal(dir, baseURL string) *Local {
+	return &Local{Dir: dir, BaseURL: baseURL}
+}
+
+func (l *Local) path(key string) (string, error) {
+	if !ValidKey(key) {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return filepath.Join(l.Dir, key), nil
+}
+
+// Ping checks that Dir exists, creating it if needed, and is writable.
+func (l *Local) Ping(ctx context.Context) error {
+	if err := os.MkdirAll(l.Dir, 0755); err != nil {
+		return fmt.Errorf("create storage dir: %w", err)
+	}
+	f, err := os.CreateTemp(l.Dir, ".ping-*")
+	if err != nil {
+		return fmt.Errorf("storage dir not writable: %w", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// Put writes r to a temp file and renames it into place.
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, contentType string) (Object, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return Object{}, err
+	}
+	if err := os.MkdirAll(l.Dir, 0755); err != nil {
+		return Object{}, fmt.Errorf("create storage dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(l.Dir, ".upload-*")
+	if err != nil {
+		return Object{}, fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), r); err != nil {
+		tmp.Close()
+		return Object{}, fmt.Errorf("write file: %w", err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return Object{}, fmt.Errorf("chmod file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return Object{}, fmt.Errorf("close file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return Object{}, fmt.Errorf("rename file: %w", err)
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return Object{}, fmt.Errorf("stat file: %w", err)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	l.writeSum(key, fi, sum)
+	return l.object(key, fi, sum), nil
+}
+
+// Get opens the file for key.
+func (l *Local) Get(ctx context.Context, key string) (io.ReadSeekCloser, Object, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, Object{}, err
+	}
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, Object{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, Object{}, fmt.Errorf("open file: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, Object{}, fmt.Errorf("stat file: %w", err)
+	}
+	return f, l.object(key, fi, l.sum(key, fi, f)), nil
+}
+
+// Delete removes the file for key.
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("remove file: %w", err)
+	}
+	os.Remove(l.sumPath(key))
+	return nil
+}
+
+// Stat returns file info for key.
+func (l *Local) Stat(ctx context.Context, key string) (Object, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return Object{}, err
+	}
+	fi, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return Object{}, ErrNotFound
+	}
+	if err != nil {
+		return Object{}, fmt.Errorf("stat file: %w", err)
+	}
+	return l.object(key, fi, l.sum(key, fi, nil)), nil
+}
+
+// List returns every regular file in Dir whose name starts with prefix.
+func (l *Local) List(ctx context.Context, prefix string) ([]Object, error) {
+	entries, err := os.ReadDir(l.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read storage dir: %w", err)
+	}
+	var out []Object
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") || !strings.HasPrefix(e.Name(), prefix) {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		out = append(out, l.object(e.Name(), fi, l.sum(e.Name(), fi, nil)))
+	}
+	return out, nil
+}
+
+// URL returns BaseURL + key.
+func (l *Local) URL(key string) string {
+	return l.BaseURL + key
+}
+
+func (l *Local) object(key string, fi os.FileInfo, sum string) Object {
+	obj := Object{
+		Key:         key,
+		Size:        fi.Size(),
+		ContentType: ContentTypeFor(key),
+		ModTime:     fi.ModTime().UTC(),
+		SHA256:      sum,
+	}
+	obj.URL = versioned(l.URL(key), obj)
+	return obj
+}
+
+func (l *Local) sumPath(key string) string {
+	return filepath.Join(l.Dir, "."+key+".sha256")
+}
+
+// sumStamp ties a sidecar to one version of its file, so a file replaced
+// behind the store's back is hashed again.
+func sumStamp(fi os.FileInfo) string {
+	return fmt.Sprintf("%d %d", fi.Size(), fi.ModTime().UnixNano())
+}
+
+// sum returns the SHA-256 of the file for key, whose info is fi, from its
+// sidecar, or hashes the file, through f when it is already open, and records
+// the result. It returns "" if the file cannot be read.
+func (l *Local) sum(key string, fi os.FileInfo, f *os.File) string {
+	if b, err := os.ReadFile(l.sumPath(key)); err == nil {
+		sum, stamp, _ := strings.Cut(strings.TrimSpace(string(b)), " ")
+		if stamp == sumStamp(fi) && len(sum) == sha256.Size*2 {
+			return sum
+		}
+	}
+	if f == nil {
+		var err error
+		if f, err = os.Open(filepath.Join(l.Dir, key)); err != nil {
+			return ""
+		}
+		defer f.Close()
+	}
+	// a section reader leaves f's offset alone for the caller
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(f, 0, fi.Size())); err != nil {
+		return ""
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	l.writeSum(key, fi, sum)
+	return sum
+}
+
+// writeSum records sum for key. Failing to is harmless; the file is hashed
+// again next time.
+func (l *Local) writeSum(key string, fi os.FileInfo, sum string) {
+	tmp, err := os.CreateTemp(l.Dir, ".sum-*")
+	if err != nil {
+		return
+	}
+	_, err = fmt.Fprintf(tmp, "%s %s\n", sum, sumStamp(fi))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), l.sumPath(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
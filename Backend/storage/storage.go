@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ansh0014/KolamApp/config"
+)
+
+// ErrNotFound is returned when a key does not exist in the store.
+var ErrNotFound = errors.New("object not found")
+
+// Object describes a stored blob.
+type Object struct {
+	Key         string    `json:"key"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type,omitempty"`
+	ModTime     time.Time `json:"mod_time"`
+	// SHA256 is the hex SHA-256 digest of the content, or "" if the store
+	// does not know it (Cloudinary assets uploaded before it was recorded).
+	SHA256 string `json:"sha256,omitempty"`
+	// URL is the public URL of this version of the object: stores served
+	// through /images add ?v=<Version>, Cloudinary URLs carry their own version.
+	URL string `json:"url"`
+}
+
+// Version returns the short content version of obj used in ?v= URL
+// parameters, or "" if its hash is unknown.
+func Version(obj Object) string {
+	if len(obj.SHA256) < versionLen {
+		return ""
+	}
+	return obj.SHA256[:versionLen]
+}
+
+// versionLen is how many hex digits of the hash a version keeps: 64 bits,
+// plenty to tell versions of one key apart.
+const versionLen = 16
+
+// versioned appends obj's version to url, if it has one.
+func versioned(url string, obj Object) string {
+	if v := Version(obj); v != "" {
+		return url + "?v=" + v
+	}
+	return url
+}
+
+// BlobStore is implemented by every storage provider (local disk, Cloudinary, memory).
+// Keys are flat names such as "kolam_1-19-1_traditional_1700000000.png".
+type BlobStore interface {
+	// Put stores r under key and returns the stored object.
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (Object, error)
+	// Get opens the object for reading. The reader seeks, so byte ranges
+	// can be served from any store; the caller must close it.
+	Get(ctx context.Context, key string) (io.ReadSeekCloser, Object, error)
+	// Delete removes the object. Deleting a missing key returns ErrNotFound.
+	Delete(ctx context.Context, key string) error
+	// Stat returns object info without reading its content.
+	Stat(ctx context.Context, key string) (Object, error)
+	// List returns all objects whose key starts with prefix.
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// URL returns the public URL clients should use to fetch key.
+	URL(key string) string
+	// Ping checks that the store is reachable and accepts writes.
+	Ping(ctx context.Context) error
+}
+
+// opener is implemented by stores for which Get costs a metadata lookup that
+// a caller who already has the object's metadata can skip.
+type opener interface {
+	open(ctx context.Context, obj Object) io.ReadSeekCloser
+}
+
+// Open is Get for an object whose metadata the caller already has, such as
+// from the image's database record. Stores where looking an object up is
+// expensive (Cloudinary's rate-limited Admin API) read it straight from
+// obj.URL; obj must then have its Size and URL set. Others fall back to Get.
+func Open(ctx context.Context, store BlobStore, obj Object) (io.ReadSeekCloser, Object, error) {
+	if o, ok := store.(opener); ok && obj.Size > 0 && obj.URL != "" {
+		return o.open(ctx, obj), obj, nil
+	}
+	return store.Get(ctx, obj.Key)
+}
+
+// New builds the BlobStore selected by config.StorageProvider.
+// Call config.InitStorageConfig() and config.InitCloudinaryConfig() first.
+func New() (BlobStore, error) {
+	switch config.StorageProvider {
+	case "local":
+		return NewLocal(config.StorageDir, "/images/"), nil
+	case "cloudinary":
+		return NewCloudinary(config.CloudinaryFolder)
+	case "memory":
+		return NewMemory("/images/"), nil
+	default:
+		return nil, fmt.Errorf("unknown storage provider %q", config.StorageProvider)
+	}
+}
+
+// ValidKey reports whether key is a safe flat object name.
+func ValidKey(key string) bool {
+	if key == "" || key == "." || key == ".." {
+		return false
+	}
+	return !strings.ContainsAny(key, `/\`) && !strings.Contains(key, "..")
+}
+
+// ContentTypeFor guesses a content type from the key extension.
+func ContentTypeFor(key string) string {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".png":
+		return "image/png"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".gif":
+		return "image/gif"
+	case ".webp":
+		return "image/webp"
+	case ".svg":
+		return "image/svg+xml"
+	default:
+		return "application/octet-stream"
+	}
+}
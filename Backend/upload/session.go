@@ -0,0 +1,367 @@
+package upload
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrSessionNotFound is returned for unknown or expired sessions and for
+	// sessions owned by someone else.
+	ErrSessionNotFound = errors.New("upload session not found")
+	// ErrOffsetMismatch is returned when a chunk does not start where the
+	// received data ends; the returned Session carries the right offset.
+	ErrOffsetMismatch = errors.New("chunk offset does not match upload offset")
+	// ErrSessionBusy is returned when another chunk is being written to the session.
+	ErrSessionBusy = errors.New("another chunk is being written to this upload")
+	// ErrIncomplete is returned when finishing a session that has not received all its data.
+	ErrIncomplete = errors.New("upload is incomplete")
+	// ErrOwnerLimit is returned by Create when the owner already has
+	// MaxPerOwner sessions open or the new one would reserve more than
+	// MaxBytesPerOwner.
+	ErrOwnerLimit = errors.New("too many uploads in progress")
+)
+
+// Session is a resumable upload in progress. Offset is how many bytes of
+// Size have been received; data written before a dropped connection is kept,
+// so a client resumes from Offset rather than from its last full chunk.
+type Session struct {
+	ID        string    `json:"id"`
+	Owner     string    `json:"owner,omitempty"`
+	Size      int64     `json:"size"`
+	Offset    int64     `json:"offset"`
+	Tags      []string  `json:"tags,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Complete reports whether all of the upload's data has been received.
+func (s *Session) Complete() bool { return s.Offset == s.Size }
+
+// Sessions keeps resumable uploads on local disk: each session is a data
+// file and a JSON sidecar in dir. A session expires TTL after its last chunk.
+// Unless they are zero, MaxPerOwner caps the sessions an owner may have open
+// and MaxBytesPerOwner the total size they declare, so one account cannot
+// reserve the disk. Both the files and the locks are local to the process,
+// so all requests for a session must reach the server that created it.
+type Sessions struct {
+	dir              string
+	TTL              time.Duration
+	MaxPerOwner      int
+	MaxBytesPerOwner int64
+
+	mu   sync.Mutex
+	busy map[string]bool
+	// creating serializes Create so concurrent calls cannot both pass the owner limits
+	creating sync.Mutex
+}
+
+var validSessionID = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// OpenSessions opens or creates the session store in dir.
+func OpenSessions(dir string, ttl time.Duration) (*Sessions, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create upload session dir: %w", err)
+	}
+	return &Sessions{dir: dir, TTL: ttl, busy: make(map[string]bool)}, nil
+}
+
+func (s *Sessions) dataPath(id string) string { return filepath.Join(s.dir, id) }
+func (s *Sessions) metaPath(id string) string { return filepath.Join(s.dir, id+".json") }
+
+// Create starts a session for size bytes owned by owner, or returns
+// ErrOwnerLimit if that would take owner over MaxPerOwner or MaxBytesPerOwner.
+func (s *Sessions) Create(owner string, size int64, tags []string) (*Session, error) {
+	s.creating.Lock()
+	defer s.creating.Unlock()
+	if err := s.checkOwnerLimits(owner, size); err != nil {
+		return nil, err
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("generate upload id: %w", err)
+	}
+	now := time.Now().UTC()
+	sess := &Session{
+		ID:        hex.EncodeToString(b),
+		Owner:     owner,
+		Size:      size,
+		Tags:      tags,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.TTL),
+	}
+	f, err := os.OpenFile(s.dataPath(sess.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("create upload file: %w", err)
+	}
+	f.Close()
+	if err := s.save(sess); err != nil {
+		s.remove(sess.ID)
+		return nil, err
+	}
+	return sess, nil
+}
+
+// Get returns owner's session id.
+func (s *Sessions) Get(id, owner string) (*Session, error) {
+	return s.load(id, owner)
+}
+
+// Write appends r to the session, which must have received exactly offset
+// bytes so far, and returns the session with its new offset. Whatever part of
+// r arrived is kept even if reading it fails. Data beyond the declared size is
+// refused with ErrTooLarge and discarded.
+func (s *Sessions) Write(id, owner string, offset int64, r io.Reader) (*Session, error) {
+	if !s.acquire(id) {
+		return nil, ErrSessionBusy
+	}
+	defer s.release(id)
+	sess, err := s.load(id, owner)
+	if err != nil {
+		return nil, err
+	}
+	if offset != sess.Offset {
+		return sess, ErrOffsetMismatch
+	}
+
+	f, err := os.OpenFile(s.dataPath(id), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open upload file: %w", err)
+	}
+	n, copyErr := io.Copy(f, io.LimitReader(r, sess.Size-sess.Offset+1))
+	if sess.Offset+n > sess.Size {
+		copyErr = fmt.Errorf("%w: more than the declared %d bytes", ErrTooLarge, sess.Size)
+		n = 0
+		if err := f.Truncate(sess.Offset); err != nil {
+			copyErr = fmt.Errorf("discard excess upload data: %w", err)
+		}
+	}
+	if err := f.Close(); err != nil && copyErr == nil {
+		copyErr = fmt.Errorf("write upload file: %w", err)
+	}
+
+	sess.Offset += n
+	sess.ExpiresAt = time.Now().UTC().Add(s.TTL)
+	if err := s.save(sess); err != nil {
+		return nil, err
+	}
+	return sess, copyErr
+}
+
+// Head returns up to n of the first bytes received, for sniffing the content type early.
+func (s *Sessions) Head(id string, n int) ([]byte, error) {
+	f, err := os.Open(s.dataPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("open upload file: %w", err)
+	}
+	defer f.Close()
+	b := make([]byte, n)
+	n, err = io.ReadFull(f, b)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("read upload file: %w", err)
+	}
+	return b[:n], nil
+}
+
+// Open returns the data of a complete session for reading. The session is
+// held busy until release is called, so no chunk can be written meanwhile.
+// An incomplete session is returned along with ErrIncomplete.
+func (s *Sessions) Open(id, owner string) (sess *Session, f *os.File, release func(), err error) {
+	if !s.acquire(id) {
+		return nil, nil, nil, ErrSessionBusy
+	}
+	sess, err = s.load(id, owner)
+	if err == nil && !sess.Complete() {
+		s.release(id)
+		return sess, nil, nil, fmt.Errorf("%w: received %d of %d bytes", ErrIncomplete, sess.Offset, sess.Size)
+	}
+	if err == nil {
+		f, err = os.Open(s.dataPath(id))
+	}
+	if err != nil {
+		s.release(id)
+		return nil, nil, nil, err
+	}
+	return sess, f, func() { f.Close(); s.release(id) }, nil
+}
+
+// Delete discards owner's session id.
+func (s *Sessions) Delete(id, owner string) error {
+	if !s.acquire(id) {
+		return ErrSessionBusy
+	}
+	defer s.release(id)
+	if _, err := s.load(id, owner); err != nil {
+		return err
+	}
+	s.remove(id)
+	return nil
+}
+
+// Start removes expired sessions every interval until ctx is done.
+func (s *Sessions) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			s.expire()
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// checkOwnerLimits returns ErrOwnerLimit if owner may not open another
+// session of size bytes.
+func (s *Sessions) checkOwnerLimits(owner string, size int64) error {
+	if s.MaxPerOwner <= 0 && s.MaxBytesPerOwner <= 0 {
+		return nil
+	}
+	open, reserved, err := s.usage(owner)
+	if err != nil {
+		return err
+	}
+	if s.MaxPerOwner > 0 && open >= s.MaxPerOwner {
+		return fmt.Errorf("%w: %d open, the limit is %d", ErrOwnerLimit, open, s.MaxPerOwner)
+	}
+	if s.MaxBytesPerOwner > 0 && reserved+size > s.MaxBytesPerOwner {
+		return fmt.Errorf("%w: %d bytes already reserved, the limit is %d", ErrOwnerLimit, reserved, s.MaxBytesPerOwner)
+	}
+	return nil
+}
+
+// usage returns how many unexpired sessions owner has and their total size.
+func (s *Sessions) usage(owner string) (open int, reserved int64, err error) {
+	names, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, 0, fmt.Errorf("list upload sessions: %w", err)
+	}
+	now := time.Now()
+	for _, n := range names {
+		id, ok := strings.CutSuffix(n.Name(), ".json")
+		if !ok || !validSessionID.MatchString(id) {
+			continue
+		}
+		sess, err := s.read(id)
+		if err != nil || sess.Owner != owner || !now.Before(sess.ExpiresAt) {
+			continue
+		}
+		open++
+		reserved += sess.Size
+	}
+	return open, reserved, nil
+}
+
+func (s *Sessions) expire() {
+	names, err := os.ReadDir(s.dir)
+	if err != nil {
+		log.Printf("upload sessions: %v", err)
+		return
+	}
+	for _, n := range names {
+		id, ok := strings.CutSuffix(n.Name(), ".json")
+		if !ok || !validSessionID.MatchString(id) {
+			continue
+		}
+		sess, err := s.read(id)
+		if err == nil && time.Now().Before(sess.ExpiresAt) {
+			continue
+		}
+		if s.acquire(id) {
+			s.remove(id)
+			s.release(id)
+		}
+	}
+}
+
+func (s *Sessions) load(id, owner string) (*Session, error) {
+	if !validSessionID.MatchString(id) {
+		return nil, ErrSessionNotFound
+	}
+	sess, err := s.read(id)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sess.Owner != owner || time.Now().After(sess.ExpiresAt) {
+		return nil, ErrSessionNotFound
+	}
+	// the data file, not the sidecar, says how much was received
+	fi, err := os.Stat(s.dataPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("stat upload file: %w", err)
+	}
+	sess.Offset = min(fi.Size(), sess.Size)
+	return sess, nil
+}
+
+func (s *Sessions) read(id string) (*Session, error) {
+	b, err := os.ReadFile(s.metaPath(id))
+	if err != nil {
+		return nil, err
+	}
+	var sess Session
+	if err := json.Unmarshal(b, &sess); err != nil {
+		return nil, fmt.Errorf("decode upload session %s: %w", id, err)
+	}
+	return &sess, nil
+}
+
+func (s *Sessions) save(sess *Session) error {
+	b, err := json.Marshal(sess)
+	if err != nil {
+		return fmt.Errorf("encode upload session: %w", err)
+	}
+	tmp := s.metaPath(sess.ID) + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("write upload session: %w", err)
+	}
+	if err := os.Rename(tmp, s.metaPath(sess.ID)); err != nil {
+		return fmt.Errorf("write upload session: %w", err)
+	}
+	return nil
+}
+
+func (s *Sessions) remove(id string) {
+	for _, p := range []string{s.metaPath(id), s.dataPath(id)} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("upload sessions: %v", err)
+		}
+	}
+}
+
+func (s *Sessions) acquire(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.busy[id] {
+		return false
+	}
+	s.busy[id] = true
+	return true
+}
+
+func (s *Sessions) release(id string) {
+	s.mu.Lock()
+	delete(s.busy, id)
+	s.mu.Unlock()
+}
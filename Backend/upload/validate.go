@@ -0,0 +1,135 @@
+// Package upload checks user-supplied image files before they are stored.
+package upload
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrTooLarge is returned for files over Limits.MaxBytes.
+	ErrTooLarge = errors.New("file too large")
+	// ErrUnsupportedType is returned for anything but PNG, JPEG and GIF images.
+	ErrUnsupportedType = errors.New("unsupported file type")
+	// ErrCorrupt is returned when the content looks like an image but cannot be decoded.
+	ErrCorrupt = errors.New("image could not be decoded")
+	// ErrDimensions is returned for images over the width, height or pixel limits.
+	ErrDimensions = errors.New("image dimensions too large")
+)
+
+// Limits bounds what Validate accepts.
+type Limits struct {
+	MaxBytes     int64
+	MaxDimension int
+	MaxPixels    int
+}
+
+// Info describes a validated image. Ext is the canonical extension of its
+// format, including the dot.
+type Info struct {
+	Format      string
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+}
+
+// formats are the accepted formats, keyed by sniffed content type.
+var formats = map[string]Info{
+	"image/png":  {Format: "png", ContentType: "image/png", Ext: ".png"},
+	"image/jpeg": {Format: "jpeg", ContentType: "image/jpeg", Ext: ".jpg"},
+	"image/gif":  {Format: "gif", ContentType: "image/gif", Ext: ".gif"},
+}
+
+// Validate checks that r, of the given size, is an accepted image within
+// lim, judging by its content alone: the client's file name and content type
+// are not trusted. r is rewound before Validate returns successfully.
+func Validate(r io.ReadSeeker, size int64, lim Limits) (Info, error) {
+	if size > lim.MaxBytes {
+		return Info{}, fmt.Errorf("%w: %d bytes, the limit is %d", ErrTooLarge, size, lim.MaxBytes)
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return Info{}, fmt.Errorf("read upload: %w", err)
+	}
+	info, err := CheckType(head[:n])
+	if err != nil {
+		return Info{}, err
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return Info{}, fmt.Errorf("rewind upload: %w", err)
+	}
+	cfg, format, err := image.DecodeConfig(r)
+	if err != nil || format != info.Format || cfg.Width <= 0 || cfg.Height <= 0 {
+		return Info{}, fmt.Errorf("%w as %s", ErrCorrupt, info.Format)
+	}
+	if cfg.Width > lim.MaxDimension || cfg.Height > lim.MaxDimension || cfg.Width*cfg.Height > lim.MaxPixels {
+		return Info{}, fmt.Errorf("%w: %dx%d, the limits are %d pixels a side and %d in total",
+			ErrDimensions, cfg.Width, cfg.Height, lim.MaxDimension, lim.MaxPixels)
+	}
+	info.Width, info.Height = cfg.Width, cfg.Height
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return Info{}, fmt.Errorf("rewind upload: %w", err)
+	}
+	return info, nil
+}
+
+// CheckType returns the format of a file from its first bytes, or
+// ErrUnsupportedType if it is not an accepted image. 512 bytes are enough to tell.
+func CheckType(head []byte) (Info, error) {
+	sniffed := Sniff(head)
+	info, ok := formats[sniffed]
+	if !ok {
+		return Info{}, fmt.Errorf("%w: %s; upload a PNG, JPEG or GIF image", ErrUnsupportedType, describe(sniffed))
+	}
+	return info, nil
+}
+
+// heifBrands are the ISO base media brands of HEIC and AVIF stills, which
+// http.DetectContentType does not know.
+var heifBrands = map[string]string{
+	"heic": "image/heic", "heix": "image/heic", "heim": "image/heic", "heis": "image/heic",
+	"hevc": "image/heic", "mif1": "image/heif", "msf1": "image/heif", "avif": "image/avif",
+}
+
+// Sniff returns the content type of a file from its first bytes.
+func Sniff(head []byte) string {
+	ct, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" {
+		// ISO base media: a HEIF still, or for any other brand a video
+		if still, ok := heifBrands[string(head[8:12])]; ok {
+			return still
+		}
+		if ct == "application/octet-stream" {
+			return "video/mp4"
+		}
+	}
+	return ct
+}
+
+// describe names a rejected content type in terms a user would recognise.
+func describe(contentType string) string {
+	switch {
+	case contentType == "image/heic" || contentType == "image/heif":
+		return "HEIC photos are not supported"
+	case contentType == "image/avif":
+		return "AVIF images are not supported"
+	case strings.HasPrefix(contentType, "video/"):
+		return "video files are not supported"
+	case strings.HasPrefix(contentType, "image/"):
+		return strings.ToUpper(strings.TrimPrefix(contentType, "image/")) + " images are not supported"
+	case strings.HasPrefix(contentType, "text/"):
+		return "text files are not supported"
+	}
+	return "the file is not a recognised image"
+}
@@ -0,0 +1,126 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+)
+
+var testLimits = Limits{MaxBytes: 1 << 20, MaxDimension: 4096, MaxPixels: 4_000_000}
+
+func encoded(t *testing.T, encode func(io.Writer, image.Image) error) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := encode(&buf, image.NewRGBA(image.Rect(0, 0, 30, 20))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// pngHeader is a PNG that declares w×h pixels but holds no image data, as
+// a decompression bomb's first bytes would.
+func pngHeader(w, h uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], w)
+	binary.BigEndian.PutUint32(ihdr[4:], h)
+	ihdr[8], ihdr[9] = 8, 2 // 8-bit RGB
+	var b bytes.Buffer
+	b.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&b, binary.BigEndian, uint32(len(ihdr)))
+	b.WriteString("IHDR")
+	b.Write(ihdr)
+	binary.Write(&b, binary.BigEndian, crc32.ChecksumIEEE(append([]byte("IHDR"), ihdr...)))
+	return b.Bytes()
+}
+
+func TestValidateAccepts(t *testing.T) {
+	tests := []struct {
+		name, format, ext string
+		data              []byte
+	}{
+		{"png", "png", ".png", encoded(t, png.Encode)},
+		{"jpeg", "jpeg", ".jpg", encoded(t, func(w io.Writer, m image.Image) error { return jpeg.Encode(w, m, nil) })},
+		{"gif", "gif", ".gif", encoded(t, func(w io.Writer, m image.Image) error { return gif.Encode(w, m, nil) })},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bytes.NewReader(tt.data)
+			info, err := Validate(r, int64(len(tt.data)), testLimits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Format != tt.format || info.Ext != tt.ext || info.Width != 30 || info.Height != 20 {
+				t.Errorf("info = %+v", info)
+			}
+			rest, _ := io.ReadAll(r)
+			if !bytes.Equal(rest, tt.data) {
+				t.Error("reader was not rewound")
+			}
+		})
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	pngData := encoded(t, png.Encode)
+	jpegData := encoded(t, func(w io.Writer, m image.Image) error { return jpeg.Encode(w, m, nil) })
+	tests := []struct {
+		name string
+		data []byte
+		// size is the declared size, if not len(data)
+		size int64
+		want error
+	}{
+		// the client may call these image/png; only the bytes count
+		{name: "html sent as an image", data: []byte("<!DOCTYPE html><html><script>alert(1)</script></html>"), want: ErrUnsupportedType},
+		{name: "svg sent as an image", data: []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"></svg>`), want: ErrUnsupportedType},
+		{name: "heic sent as an image", data: []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic"), want: ErrUnsupportedType},
+		{name: "png signature on jpeg data", data: append([]byte("\x89PNG\r\n\x1a\n"), jpegData...), want: ErrCorrupt},
+		{name: "empty", data: nil, want: ErrUnsupportedType},
+
+		{name: "decompression bomb", data: pngHeader(100_000, 100_000), want: ErrDimensions},
+		{name: "too wide", data: pngHeader(5000, 10), want: ErrDimensions},
+		{name: "too many pixels", data: pngHeader(4000, 4000), want: ErrDimensions},
+
+		{name: "truncated", data: pngData[:20], want: ErrCorrupt},
+		{name: "truncated header", data: pngHeader(30, 20)[:24], want: ErrCorrupt},
+
+		{name: "oversize", data: pngData, size: testLimits.MaxBytes + 1, want: ErrTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size := tt.size
+			if size == 0 {
+				size = int64(len(tt.data))
+			}
+			_, err := Validate(bytes.NewReader(tt.data), size, testLimits)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckTypeNamesRejectedFormats(t *testing.T) {
+	tests := []struct {
+		head, want string
+	}{
+		{"\x00\x00\x00\x18ftypheic", "HEIC photos are not supported"},
+		{"\x00\x00\x00\x18ftypavif", "AVIF images are not supported"},
+		{"\x00\x00\x00\x18ftypqt  ", "video files are not supported"},
+		{"RIFF\x00\x00\x00\x00WEBPVP8 ", "WEBP images are not supported"},
+		{"hello", "text files are not supported"},
+	}
+	for _, tt := range tests {
+		_, err := CheckType([]byte(tt.head))
+		if !errors.Is(err, ErrUnsupportedType) || !bytes.Contains([]byte(err.Error()), []byte(tt.want)) {
+			t.Errorf("CheckType(%q) = %v, want %q", tt.head, err, tt.want)
+		}
+	}
+}
@@ -6,19 +6,47 @@ const { width } = Dimensions.get('window');
 const CANVAS_SIZE = width * 0.9;
 
 export default function KolamCanvas({ dots = [], strokes = [], animate = false }) {
-  const [drawnPoints, setDrawnPoints] = useState([]);
+  // number of points drawn so far across all strokes, in order
+  const total = strokes.reduce((n, st) => n + st.length, 0);
+  const [drawn, setDrawn] = useState(total);
   const timer = useRef(null);
 
   useEffect(() => {
-    if (animate && strokes.length > 0) {
-      setDrawnPoints([]);
+    if (animate && total > 0) {
+      setDrawn(0);
       let i = 0;
       timer.current = setInterval(() => {
         i += 5; // Draw 5 points per tick
-        setDrawnPoints(strokes[0].slice(0, i));
-        if (i >= strokes[0].length) clearInterval(timer.current);
+        setDrawn(i);
+        if (i >= total) clearInterval(timer.current);
       }, 16);
       return () => clearInterval(timer.current);
+    }
+    setDrawn(total);
+  }, [strokes, animate]);
+
+  // Split the drawn budget over the strokes in drawing order
+  let budget = drawn;
+  const drawnStrokes = strokes.map(st => {
+    const part = st.slice(0, Math.max(0, budget));
+    budget -= st.length;
+    return part;
+  });
+
+  // Normalize coordinates to fit canvas
+  const allPoints = dots.concat(...strokes);
+  const allX = allPoints.map(([x]) => x);
+  const allY = allPoints.map(([, y]) => y);
+  const minX = Math.min(...allX, 0), maxX = Math.max(...allX, 1);
+  const minY = Math.min(...allY, 0), maxY = Math.max(...allY, 1);
+  const scale = Math.min(CANVAS_SIZE / (maxX - minX + 2), CANVAS_SIZE / (maxY - minY + 2));
+  const offsetX = (CANVAS_SIZE - scale * (maxX - minX)) / 2;
+  const offsetY = (CANVAS_SIZE - scale * (maxY - minY)) / 2;
+
+  const tx = x => offsetX + scale * (x - minX);
+  const ty = y => offsetY + scale * (y - minY);
+
+  return () => clearInterval(timer.current);
     } else if (strokes.length > 0) {
       setDrawnPoints(strokes[0]);
     }
@@ -42,14 +70,15 @@ export default function KolamCanvas({ dots = [], strokes = [], animate = false }
         {dots.map(([x, y], i) => (
           <Circle key={i} cx={tx(x)} cy={ty(y)} r={4} fill="#333" />
         ))}
-        {drawnPoints.length > 1 && (
+        {drawnStrokes.map((points, i) => points.length > 1 && (
           <Polyline
-            points={drawnPoints.map(([x, y]) => `${tx(x)},${ty(y)}`).join(' ')}
+            key={i}
+            points={points.map(([x, y]) => `${tx(x)},${ty(y)}`).join(' ')}
             fill="none"
             stroke="#d81b60"
             strokeWidth={3}
           />
-        )}
+        ))}
       </Svg>
     </View>
   );
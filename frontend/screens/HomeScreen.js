@@ -1,8 +1,9 @@
-import React from 'react';
-import { View, Text, StyleSheet, TouchableOpacity, Image, Dimensions } from 'react-native';
+import React, { useCallback, useEffect, useState } from 'react';
+import { View, Text, StyleSheet, TouchableOpacity, Image, Dimensions, FlatList, ActivityIndicator } from 'react-native';
 import Icon from 'react-native-vector-icons/Ionicons';
 import Carousel from 'react-native-reanimated-carousel';
 import { LinearGradient } from 'expo-linear-gradient';
+import { listKolams, imageUrl } from '../utils/api';
 
 const { width } = Dimensions.get('window');
 
@@ -13,6 +14,32 @@ const images = [
 ];
 
 export default function HomeScreen({ navigation }) {
+  const owner = 'me';
+  const [kolams, setKolams] = useState([]);
+  const [cursor, setCursor] = useState(null);
+  const [loading, setLoading] = useState(false);
+  const [done, setDone] = useState(false);
+
+  const loadMore = useCallback(async (reset = false) => {
+    if (loading || (done && !reset)) return;
+    setLoading(true);
+    try {
+      const page = await listKolams({ owner, cursor: reset ? null : cursor });
+      setKolams(prev => (reset ? page.items : [...prev, ...page.items]));
+      setCursor(page.next_cursor || null);
+      setDone(!page.next_cursor);
+    } catch (e) {
+      console.warn('Failed to load kolams', e);
+    } finally {
+      setLoading(false);
+    }
+  }, [owner, cursor, loading, done]);
+
+  useEffect(() => {
+    const unsubscribe = navigation.addListener('focus', () => loadMore(true));
+    return unsubscribe;
+  }, [navigation, owner]);
+
   return (
     <LinearGradient
     colors={['#ffe29f', '#ffa99f']}
@@ -68,6 +95,25 @@ export default function HomeScreen({ navigation }) {
           <Text style={styles.cardText}>AR View</Text>
         </TouchableOpacity>
       </View>
+
+      {/* My Kolams */}
+      <Text style={styles.sectionTitle}>My Kolams</Text>
+      <FlatList
+        horizontal
+        data={kolams}
+        keyExtractor={item => item.id}
+        renderItem={({ item }) => (
+          <View style={styles.galleryItem}>
+            <Image source={{ uri: imageUrl(item, 'thumb') }} style={styles.galleryImage} />
+            <Text style={styles.galleryCaption}>{item.grid} · {item.style}</Text>
+          </View>
+        )}
+        onEndReached={() => loadMore()}
+        onEndReachedThreshold={0.5}
+        ListFooterComponent={loading ? <ActivityIndicator color="#4f46e5" /> : null}
+        ListEmptyComponent={!loading ? <Text style={styles.galleryEmpty}>No kolams yet. Generate one!</Text> : null}
+        showsHorizontalScrollIndicator={false}
+      />
     </LinearGradient>
   );
 }
@@ -142,4 +188,31 @@ const styles = StyleSheet.create({
     fontWeight: '700', 
     color: '#2c3e50' 
   },
+
+  sectionTitle: {
+    fontSize: 20,
+    fontWeight: 'bold',
+    color: '#1e293b',
+    marginTop: 28,
+    marginBottom: 12,
+  },
+  galleryItem: {
+    marginRight: 14,
+    alignItems: 'center',
+  },
+  galleryImage: {
+    width: 110,
+    height: 110,
+    borderRadius: 14,
+    backgroundColor: '#fff',
+  },
+  galleryCaption: {
+    marginTop: 6,
+    fontSize: 13,
+    color: '#475569',
+  },
+  galleryEmpty: {
+    fontSize: 15,
+    color: '#475569',
+  },
 });
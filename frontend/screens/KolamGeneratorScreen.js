@@ -1,4 +1,4 @@
-import React, { useState } from "react";
+import React, { useEffect, useRef, useState } from "react";
 import { 
   View, 
   Text, 
@@ -7,36 +7,80 @@ import {
   ScrollView, 
   ActivityIndicator, 
   Alert, 
-  Image 
+  Image,
+  TextInput
 } from "react-native";
 import DotGridSelector from "../components/DotGridSelector";
-import { generateKolam } from "../utils/api";
+import KolamCanvas from "../components/KolamCanvas";
+import { submitGenerateJob, streamJobEvents, imageUrl } from "../utils/api";
+
+const STAGE_LABELS = {
+  queued: "Waiting in queue…",
+  generating: "Generating pattern…",
+  rendering: "Rendering image…",
+  uploading: "Uploading…",
+  saving: "Saving…",
+};
 
 export default function KolamGeneratorScreen() {
   const [gridSize, setGridSize] = useState("1-19-1");
   const [loading, setLoading] = useState(false);
   const [imgUrl, setImgUrl] = useState(null);
+  const [seedText, setSeedText] = useState("");
+  const [seed, setSeed] = useState(null);
+  const [stage, setStage] = useState(null);
+  const [dots, setDots] = useState([]);
+  const [strokes, setStrokes] = useState([]);
+  const stopStream = useRef(null);
+
+  useEffect(() => () => stopStream.current && stopStream.current(), []);
 
   const handleGenerate = async () => {
     setLoading(true);
     setImgUrl(null);
+    setDots([]);
+    setStrokes([]);
+    setStage("queued");
+    if (stopStream.current) stopStream.current();
 
-    let gridtoSend = gridSize;
-    const match = /^1-(\d+)-1$/.exec(gridSize.trim());
-    if(match) {
-      gridtoSend = match[1]; 
+    try {
+      // the backend parses grid notations such as "1-19-1" itself
+      const requestedSeed = seedText.trim() === "" ? undefined : Number(seedText.trim());
+      const job = await submitGenerateJob(gridSize.trim(), "traditional", undefined, requestedSeed);
+      stopStream.current = streamJobEvents(job.id, (type, data) => {
+        switch (type) {
+          case "dots":
+            setDots(data.dots);
+            break;
+          case "stroke":
+            setStrokes(prev => [...prev, data.points]);
+            break;
+          case "done":
+            setStage(null);
+            showResult(data);
+            break;
+          case "failed":
+            setStage(null);
+            setLoading(false);
+            Alert.alert("Error", data.error || "Failed to generate kolam");
+            break;
+          default:
+            if (STAGE_LABELS[type]) setStage(type);
+        }
+      });
+    } catch (e) {
+      console.error("Generate error:", e);
+      Alert.alert("Error", e.message || "Failed to generate kolam");
+      setStage(null);
+      setLoading(false);
     }
+  };
 
+  const showResult = async (data) => {
     try {
-      const data = await generateKolam(gridtoSend, "traditional");
-      console.log("Kolam API response:", data);
-
-      // Try to resolve URL from possible fields
-      const url = 
-        data?.url || 
-        data?.secure_url || 
-        data?.secureUrl || 
-        data?.data?.url;
+      console.log("Kolam job result:", data);
+      setSeed(data?.seed ?? null);
+      const url = imageUrl(data?.kolam, "medium");
 
       console.log("Resolved image URL:", url);
 
@@ -80,11 +124,22 @@ export default function KolamGeneratorScreen() {
       {/* Grid Selector */}
       <DotGridSelector value={gridSize} onChange={setGridSize} />
 
+      {/* Optional seed to re-create a kolam */}
+      <TextInput
+        style={styles.seedInput}
+        placeholder="Seed (optional)"
+        value={seedText}
+        onChangeText={setSeedText}
+        keyboardType="number-pad"
+      />
+
       {/* Generate Button */}
       <Button title="Generate" onPress={handleGenerate} />
 
-      {/* Loader */}
+      {/* Progress */}
       {loading && <ActivityIndicator style={{ margin: 24 }} size="large" />}
+      {stage && <Text style={styles.stageText}>{STAGE_LABELS[stage]}</Text>}
+      {!imgUrl && strokes.length > 0 && <KolamCanvas dots={dots} strokes={strokes} />}
 
       {/* Result */}
       {imgUrl && (
@@ -95,6 +150,11 @@ export default function KolamGeneratorScreen() {
             resizeMode="contain"
           />
           <Text style={styles.successText}>✨ Kolam generated successfully!</Text>
+          {seed !== null && (
+            <Text style={styles.seedText} onPress={() => setSeedText(String(seed))}>
+              Seed {seed} (tap to reuse)
+            </Text>
+          )}
         </View>
       )}
     </ScrollView>
@@ -127,6 +187,25 @@ const styles = StyleSheet.create({
     borderRadius: 12,
     elevation: 3, // subtle shadow for Android
   },
+  seedInput: {
+    width: '100%',
+    borderWidth: 1,
+    borderColor: '#ddd',
+    borderRadius: 8,
+    padding: 10,
+    marginBottom: 12,
+    backgroundColor: '#fff',
+  },
+  seedText: {
+    color: '#555',
+    fontSize: 14,
+    marginTop: 6,
+  },
+  stageText: {
+    fontSize: 16,
+    color: "#555",
+    marginBottom: 12,
+  },
   successText: {
     color: 'green',
     fontSize: 16,
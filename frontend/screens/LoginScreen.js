@@ -1,11 +1,30 @@
 import React, { useState } from 'react'; 
-import { View, Text, TextInput, TouchableOpacity, StyleSheet, Image } from 'react-native';
+import { View, Text, TextInput, TouchableOpacity, StyleSheet, Image, Alert, ActivityIndicator } from 'react-native';
 import { LinearGradient } from 'expo-linear-gradient';
 import Icon from 'react-native-vector-icons/Ionicons';
+import { login, signup } from '../utils/api';
 
 export default function LoginScreen({ navigation }) {
   const [email, setEmail] = useState('');
   const [password, setPassword] = useState('');
+  const [isSignup, setIsSignup] = useState(false);
+  const [busy, setBusy] = useState(false);
+
+  const submit = async () => {
+    setBusy(true);
+    try {
+      if (isSignup) {
+        await signup(email, password);
+      } else {
+        await login(email, password);
+      }
+      navigation.replace('Home');
+    } catch (e) {
+      Alert.alert(isSignup ? 'Sign up failed' : 'Login failed', e.message);
+    } finally {
+      setBusy(false);
+    }
+  };
 
   return (
     <LinearGradient
@@ -32,6 +51,7 @@ export default function LoginScreen({ navigation }) {
           value={email}
           onChangeText={setEmail}
           keyboardType="email-address"
+          autoCapitalize="none"
         />
       </View>
 
@@ -49,9 +69,12 @@ export default function LoginScreen({ navigation }) {
 
       <TouchableOpacity
         style={styles.button}
-        onPress={() => navigation.replace('Home')}
+        onPress={submit}
+        disabled={busy}
       >
-        <Text style={styles.buttonText}>Login</Text>
+        {busy
+          ? <ActivityIndicator color="#fff" />
+          : <Text style={styles.buttonText}>{isSignup ? 'Sign Up' : 'Login'}</Text>}
       </TouchableOpacity>
 
       <TouchableOpacity onPress={() => alert('Forgot Password?')}>
@@ -60,9 +83,9 @@ export default function LoginScreen({ navigation }) {
     </View>
 
     <View style={styles.signupContainer}>
-      <Text style={styles.signupText}>Don’t have an account?</Text>
-      <TouchableOpacity onPress={() => alert('Navigate to Sign Up')}>
-        <Text style={styles.signupLink}> Sign Up</Text>
+      <Text style={styles.signupText}>{isSignup ? 'Already have an account?' : 'Don’t have an account?'}</Text>
+      <TouchableOpacity onPress={() => setIsSignup(!isSignup)}>
+        <Text style={styles.signupLink}>{isSignup ? ' Login' : ' Sign Up'}</Text>
       </TouchableOpacity>
     </View>
   </LinearGradient>
@@ -1,11 +1,84 @@
 import axios from "axios";
+import AsyncStorage from '@react-native-async-storage/async-storage';
 
 // Adjust the API_URL to point to your Go backend
 export const API_URL = 'http://10.0.2.2:8080'; // Update port if different
+// All API routes live under the versioned prefix; links returned by the
+// backend are already absolute paths, so they resolve against API_URL.
+const API_BASE = `${API_URL}/v1`;
+
+const TOKENS_KEY = 'kolam.tokens';
+
+const saveTokens = async (data) => {
+  await AsyncStorage.setItem(TOKENS_KEY, JSON.stringify({
+    access: data.access_token,
+    refresh: data.refresh_token,
+  }));
+  return data;
+};
+
+// apiError turns a failed response into an Error carrying the backend's
+// { code, message, request_id } body, so screens can branch on error.code.
+const apiError = async (response) => {
+  const text = await response.text();
+  let body = {};
+  try {
+    body = JSON.parse(text);
+  } catch (e) {
+    body = { message: text.trim() };
+  }
+  const error = new Error(body.message || `Request failed with status ${response.status}`);
+  error.status = response.status;
+  error.code = body.code;
+  error.details = body.details;
+  error.requestId = body.request_id || response.headers.get('X-Request-ID');
+  return error;
+};
+
+export const logout = () => AsyncStorage.removeItem(TOKENS_KEY);
+
+const postAuth = async (path, body) => {
+  const response = await fetch(`${API_BASE}${path}`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(body),
+  });
+  if (!response.ok) {
+    throw await apiError(response);
+  }
+  return saveTokens(await response.json());
+};
+
+// Both resolve to { user, access_token, refresh_token } and remember the tokens.
+export const signup = (email, password, name) => postAuth('/auth/signup', { email, password, name });
+export const login = (email, password) => postAuth('/auth/login', { email, password });
+
+const accessToken = async () => JSON.parse((await AsyncStorage.getItem(TOKENS_KEY)) || 'null')?.access;
+
+// authFetch is fetch with the stored access token attached. On a 401 it
+// refreshes the token pair once and retries.
+export const authFetch = async (path, options = {}) => {
+  const stored = JSON.parse((await AsyncStorage.getItem(TOKENS_KEY)) || 'null');
+  const send = (token) => fetch(`${API_BASE}${path}`, {
+    ...options,
+    headers: { ...(options.headers || {}), ...(token ? { Authorization: `Bearer ${token}` } : {}) },
+  });
+
+  let response = await send(stored?.access);
+  if (response.status === 401 && stored?.refresh) {
+    try {
+      const data = await postAuth('/auth/refresh', { refresh_token: stored.refresh });
+      response = await send(data.access_token);
+    } catch (e) {
+      await logout();
+    }
+  }
+  return response;
+};
 
 export const generateKolam = async (gridSize, style) => {
   try {
-    const response = await fetch(`${API_URL}/generate-kolam`, {
+    const response = await authFetch('/generate-kolam', {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
@@ -14,7 +87,7 @@ export const generateKolam = async (gridSize, style) => {
     });
     
     if (!response.ok) {
-      throw new Error('Network response was not ok');
+      throw await apiError(response);
     }
     
     return await response.json();
@@ -24,10 +97,84 @@ export const generateKolam = async (gridSize, style) => {
   }
 };
 
+// Queues a generation and resolves to { id, status, status_url }. Pass the
+// seed of an earlier kolam to re-create it; omit it for a new one.
+export const submitGenerateJob = async (gridSize, style, generator, seed) => {
+  const response = await authFetch('/jobs/generate', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ grid_size: gridSize, style, generator, seed }),
+  });
+  if (!response.ok) {
+    throw await apiError(response);
+  }
+  return await response.json();
+};
+
+// Follows a job's Server-Sent Events stream, calling onEvent(type, data) for
+// each event (queued, generating, dots, stroke, rendering, uploading, saving,
+// done, failed). Reconnects with Last-Event-ID if the connection drops.
+// Returns a function that stops listening.
+export const streamJobEvents = (jobId, onEvent) => {
+  let xhr = null;
+  let lastId = null;
+  let stopped = false;
+  let retries = 0;
+
+  const connect = async () => {
+    const token = await accessToken();
+    if (stopped) return;
+    xhr = new XMLHttpRequest();
+    let seen = 0;
+    let buffer = '';
+    let finished = false;
+
+    xhr.open('GET', `${API_BASE}/jobs/${jobId}/events`);
+    xhr.setRequestHeader('Accept', 'text/event-stream');
+    if (token) xhr.setRequestHeader('Authorization', `Bearer ${token}`);
+    if (lastId) xhr.setRequestHeader('Last-Event-ID', lastId);
+
+    xhr.onprogress = () => {
+      buffer += xhr.responseText.slice(seen);
+      seen = xhr.responseText.length;
+      const blocks = buffer.split('\n\n');
+      buffer = blocks.pop();
+      blocks.forEach(block => {
+        let type = 'message';
+        let data = '';
+        block.split('\n').forEach(line => {
+          if (line.startsWith('id: ')) lastId = line.slice(4);
+          else if (line.startsWith('event: ')) type = line.slice(7);
+          else if (line.startsWith('data: ')) data += line.slice(6);
+        });
+        if (!data) return; // heartbeat
+        retries = 0;
+        if (type === 'done' || type === 'failed') finished = true;
+        onEvent(type, JSON.parse(data));
+      });
+    };
+    xhr.onloadend = () => {
+      if (stopped || finished) return;
+      if (retries++ < 5) {
+        setTimeout(connect, 1000 * retries);
+      } else {
+        onEvent('failed', { status: 'failed', error: 'Lost connection to the server' });
+      }
+    };
+    xhr.send();
+  };
+
+  connect();
+  return () => {
+    stopped = true;
+    if (xhr) xhr.abort();
+  };
+};
+
 export async function classifyKolam(imageFile) {
   const formData = new FormData();
   formData.append("file", imageFile);
-  const res = await axios.post(`${API_URL}/classify`, formData, {
+  const res = await axios.post(`${API_BASE}/classify`, formData, {
     headers: { "Content-Type": "multipart/form-data" },
   });
   return res.data;
@@ -35,7 +182,7 @@ export async function classifyKolam(imageFile) {
 
 export const fetchKolamDesigns = async (gridType) => {
   try {
-    const response = await fetch(`${API_URL}/api/generate`, {
+    const response = await fetch(`${API_BASE}/api/generate`, {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
@@ -44,7 +191,7 @@ export const fetchKolamDesigns = async (gridType) => {
     });
     
     if (!response.ok) {
-      throw new Error('Network response was not ok');
+      throw await apiError(response);
     }
     
     return await response.json();
@@ -53,3 +200,30 @@ export const fetchKolamDesigns = async (gridType) => {
     throw error;
   }
 };
+
+// Lists stored kolams, newest first. Pass the next_cursor from the previous
+// page as `cursor` to load more; filters: owner ('me' for the logged-in
+// user), style, grid, tag.
+export const listKolams = async ({ cursor, limit = 20, ...filters } = {}) => {
+  const params = new URLSearchParams({ limit: String(limit) });
+  if (cursor) params.append('cursor', cursor);
+  Object.entries(filters).forEach(([key, value]) => {
+    if (value) params.append(key, value);
+  });
+
+  const response = await authFetch(`/kolams?${params.toString()}`);
+  if (!response.ok) {
+    throw await apiError(response);
+  }
+  return await response.json();
+};
+
+// Stored URLs for the local blob store are relative to the backend.
+export const absoluteUrl = (url) => (url && url.startsWith('/') ? `${API_URL}${url}` : url);
+
+// imageUrl picks the 'thumb' or 'medium' rendition of an image or kolam
+// record, falling back to the original when it is already that small.
+export const imageUrl = (item, size) => {
+  const d = item?.derivatives?.find((x) => x.size === size);
+  return absoluteUrl(d?.url || item?.url);
+};
@@ -1,8 +1,8 @@
 from fastapi import FastAPI, Response, HTTPException
 from fastapi.middleware.cors import CORSMiddleware
-from pydantic import BaseModel
+from pydantic import BaseModel, Field
 from typing import Optional
-from models.generator_stub import generate_from_grid
+from models.generator_stub import generate_from_grid, GENERATOR_VERSION, MAX_SEED
 
 app = FastAPI()
 
@@ -18,15 +18,24 @@ app.add_middleware(
 class KolamRequest(BaseModel):
     grid_size: str = "4"
     style: Optional[str] = "traditional"
+    seed: Optional[int] = Field(default=None, ge=0, le=MAX_SEED)
 
 @app.post("/generate")
 async def generate_kolam(request: KolamRequest):
     try:
-        image_bytes = generate_from_grid(request.grid_size, style=request.style)
-        return Response(content=image_bytes.getvalue(), media_type="image/png")
+        image_bytes, seed = generate_from_grid(request.grid_size, style=request.style, seed=request.seed)
+        return Response(
+            content=image_bytes.getvalue(),
+            media_type="image/png",
+            headers={"X-Kolam-Seed": str(seed), "X-Generator-Version": GENERATOR_VERSION},
+        )
     except Exception as e:
         raise HTTPException(status_code=500, detail=f"Error generating kolam: {str(e)}")
 
 @app.get("/")
 async def root():
     return {"status": "ok", "message": "Kolam ML Service is running"}
+
+@app.get("/health")
+async def health():
+    return {"status": "ok", "generator_version": GENERATOR_VERSION}
@@ -55,8 +55,15 @@ def parse_kolam_data(file_path):
 
     return patterns
 
+# Identifies the geometry this generator produces. Bump it whenever a change
+# alters the kolam drawn for an existing (grid_size, style, seed).
+GENERATOR_VERSION = "stub-1"
+
+# Largest seed accepted; seeds must round-trip through JavaScript numbers.
+MAX_SEED = 2**53 - 1
+
 # ------------------ GRID LAYOUT ------------------
-def generate_grid_layout(grid_size, id_to_pattern, allowed_top_left):
+def generate_grid_layout(grid_size, id_to_pattern, allowed_top_left, rng):
     grid = [[None] * grid_size for _ in range(grid_size)]
     half_size = grid_size // 2
     
@@ -64,10 +71,10 @@ def generate_grid_layout(grid_size, id_to_pattern, allowed_top_left):
     for r in range(half_size):
         for c in range(half_size):
             if r == 0 and c == 0:
-                chosen_id = random.choice(allowed_top_left)
+                chosen_id = rng.choice(allowed_top_left)
                 grid[r][c] = id_to_pattern[chosen_id]
             else:
-                grid[r][c] = random.choice(list(id_to_pattern.values()))
+                grid[r][c] = rng.choice(list(id_to_pattern.values()))
 
     # Mirror horizontally
     for r in range(half_size):
@@ -84,7 +91,17 @@ def generate_grid_layout(grid_size, id_to_pattern, allowed_top_left):
     return grid
 
 # ------------------ IMAGE GENERATOR ------------------
-def generate_from_grid(grid_size_str: str, style="traditional"):
+def generate_from_grid(grid_size_str: str, style="traditional", seed=None):
+    """Draw a kolam and return (png_buffer, seed).
+
+    The same grid size, style and seed always give the same geometry. A random
+    seed is chosen when none is given.
+    """
+    if seed is None:
+        seed = random.randint(0, MAX_SEED)
+    # random.Random seeded with an int produces the same sequence on every
+    # Python 3 release
+    rng = random.Random(seed)
     try:
         n = int(grid_size_str)
     except:
@@ -99,7 +116,7 @@ def generate_from_grid(grid_size_str: str, style="traditional"):
 
     allowed_top_left = [1, 3, 6, 12, 13, 16]
     id_to_pattern = {p["id"]: p for p in patterns}
-    grid_patterns = generate_grid_layout(n, id_to_pattern, allowed_top_left)
+    grid_patterns = generate_grid_layout(n, id_to_pattern, allowed_top_left, rng)
     half_size = n // 2
 
     fig, ax = plt.subplots(figsize=(n, n))
@@ -164,7 +181,8 @@ def generate_from_grid(grid_size_str: str, style="traditional"):
     plt.tight_layout()
 
     buf = BytesIO()
-    plt.savefig(buf, format="png", bbox_inches="tight", pad_inches=0.1, dpi=300)
+    # no timestamps or version strings in the PNG, so equal kolams are equal bytes
+    plt.savefig(buf, format="png", bbox_inches="tight", pad_inches=0.1, dpi=300, metadata={"Software": None})
     plt.close()
     buf.seek(0)
-    return buf
+    return buf, seed